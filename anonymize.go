@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// idAnonymizer replaces trace and span IDs with pseudonyms derived from a
+// random per-run key, so the same ID always maps to the same pseudonym within
+// a run but can't be recomputed from the real ID by anyone else
+type idAnonymizer struct {
+	key []byte
+}
+
+func newIDAnonymizer() (*idAnonymizer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &idAnonymizer{key: key}, nil
+}
+
+func (a *idAnonymizer) hash(id []byte) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write(id)
+	return mac.Sum(nil)
+}
+
+func (a *idAnonymizer) traceID(id pcommon.TraceID) pcommon.TraceID {
+	if id.IsEmpty() {
+		return id
+	}
+	var pseudo pcommon.TraceID
+	copy(pseudo[:], a.hash(id[:]))
+	return pseudo
+}
+
+func (a *idAnonymizer) spanID(id pcommon.SpanID) pcommon.SpanID {
+	if id.IsEmpty() {
+		return id
+	}
+	var pseudo pcommon.SpanID
+	copy(pseudo[:], a.hash(id[:]))
+	return pseudo
+}
+
+// anonymizeEntries returns copies of the entries with every trace, span,
+// parent and link ID replaced by its pseudonym. Parent/child relationships
+// and links are preserved because each ID maps the same way everywhere.
+func (a *idAnonymizer) anonymizeEntries(entries []traceEntry) []traceEntry {
+	anonymized := make([]traceEntry, len(entries))
+	for i, entry := range entries {
+		traces := ptrace.NewTraces()
+		entry.traces.CopyTo(traces)
+		forEachSpan(traces, func(span ptrace.Span) {
+			span.SetTraceID(a.traceID(span.TraceID()))
+			span.SetSpanID(a.spanID(span.SpanID()))
+			span.SetParentSpanID(a.spanID(span.ParentSpanID()))
+			for j := 0; j < span.Links().Len(); j++ {
+				link := span.Links().At(j)
+				link.SetTraceID(a.traceID(link.TraceID()))
+				link.SetSpanID(a.spanID(link.SpanID()))
+			}
+		})
+		entry.traces = traces
+		anonymized[i] = entry
+	}
+	return anonymized
+}
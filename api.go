@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// registerAPIHandlers adds the runtime control endpoints to the HTTP mux
+func registerAPIHandlers(mux *http.ServeMux, storage *TraceStorage, config *Config) {
+	mux.HandleFunc("/api/clear", requireAuth(config, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		cleared := storage.Clear()
+		log.Printf("API: Cleared %d stored trace batches (requested by %s)", cleared, r.RemoteAddr)
+		writeJSONResponse(w, map[string]int{"cleared_batches": cleared})
+	}))
+
+	// Read-only access to the collected traces
+	mux.HandleFunc("/api/traces", requireAuth(config, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		traces := groupTraces(storage.Snapshot().traces)
+		list := apiTraceList{Traces: make([]apiTraceSummary, 0, len(traces))}
+		for _, ti := range traces {
+			list.Traces = append(list.Traces, apiTraceSummary{
+				TraceID:    ti.traceID,
+				Service:    ti.getServiceName(config),
+				RootSpan:   ti.getRootSpanName(),
+				DurationNs: ti.getDuration().Nanoseconds(),
+				SpanCount:  len(ti.spans),
+				HasError:   ti.hasError(),
+				ReceivedAt: ti.firstArrival,
+			})
+		}
+		writeJSONResponse(w, list)
+	}))
+
+	mux.HandleFunc("/api/traces/{id}", requireAuth(config, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.ToLower(r.PathValue("id"))
+		for _, ti := range groupTraces(storage.Snapshot().traces) {
+			if ti.traceID == id {
+				writeJSONResponse(w, buildJSONTrace(ti, config))
+				return
+			}
+		}
+		http.Error(w, "Trace not found", http.StatusNotFound)
+	}))
+
+	mux.HandleFunc("/api/services", requireAuth(config, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		traces := groupTraces(storage.Snapshot().traces)
+		writeJSONResponse(w, apiServiceList{Services: computeServiceStats(traces, config)})
+	}))
+
+	// Storage limits can be adjusted during a long session without
+	// restarting and losing the collected traces
+	mux.HandleFunc("/api/config", requireAuth(config, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSONResponse(w, storage.Limits())
+		case http.MethodPost:
+			var update apiConfigUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			var expiration *time.Duration
+			if update.TraceExpiration != nil {
+				d, err := time.ParseDuration(*update.TraceExpiration)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Invalid trace_expiration: %v", err), http.StatusBadRequest)
+					return
+				}
+				expiration = &d
+			}
+
+			limits, err := storage.SetLimits(expiration, update.MaxTraces, update.MaxMemoryMB)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("API: Storage limits changed by %s: trace expiration %s, max traces %d, max memory %d MB",
+				r.RemoteAddr, limits.TraceExpiration, limits.MaxTraces, limits.MaxMemoryMB)
+			writeJSONResponse(w, limits)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSONResponse(w, openAPISpec())
+	})
+}
+
+// apiTraceList is the response of GET /api/traces
+type apiTraceList struct {
+	Traces []apiTraceSummary `json:"traces"`
+}
+
+// apiTraceSummary describes one trace without its spans; GET
+// /api/traces/{id} returns the full trace
+type apiTraceSummary struct {
+	TraceID    string    `json:"trace_id"`
+	Service    string    `json:"service"`
+	RootSpan   string    `json:"root_span"`
+	DurationNs int64     `json:"duration_ns"`
+	SpanCount  int       `json:"span_count"`
+	HasError   bool      `json:"has_error"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// apiServiceList is the response of GET /api/services
+type apiServiceList struct {
+	Services []serviceStats `json:"services"`
+}
+
+// apiConfigUpdate is the body of POST /api/config; omitted fields are left
+// unchanged and 0 disables a limit
+type apiConfigUpdate struct {
+	TraceExpiration *string `json:"trace_expiration"` // Go duration, e.g. "30m"
+	MaxTraces       *int    `json:"max_traces"`
+	MaxMemoryMB     *int    `json:"max_memory_mb"`
+}
+
+// writeJSONResponse encodes v as the JSON response body
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("HTTP: Failed to marshal response: %v", err)
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// requireAuth rejects requests without the configured bearer token
+func requireAuth(config *Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !config.authorized(r.Header.Get("Authorization")) {
+			log.Printf("HTTP: Unauthorized request to %s from %s", r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limitConcurrency lets at most limit requests run next at once (0 = no
+// limit). Requests beyond that get 503 with Retry-After, which OTLP exporters
+// treat as retryable, instead of queueing up large bodies in memory.
+func limitConcurrency(limit int, next http.HandlerFunc) http.HandlerFunc {
+	if limit <= 0 {
+		return next
+	}
+	slots := make(chan struct{}, limit)
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next(w, r)
+		default:
+			log.Printf("HTTP: Rejected request from %s: %d concurrent requests already in progress", r.RemoteAddr, limit)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many concurrent requests", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// grpcAuthInterceptor rejects gRPC calls without the configured bearer token
+func grpcAuthInterceptor(config *Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		header := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			header = strings.Join(md.Get("authorization"), "")
+		}
+		if !config.authorized(header) {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authorized reports whether an Authorization header value carries the
+// configured token; every request is authorized when no token is set
+func (c *Config) authorized(header string) bool {
+	if c.AuthToken == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(c.AuthToken)) == 1
+}
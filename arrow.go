@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// arrowUnsupportedMessage is returned to exporters that try to use the
+// experimental OTLP Arrow encoding
+const arrowUnsupportedMessage = "OTLP Arrow is not supported by tracedown, use standard OTLP (disable Arrow in your exporter)"
+
+// unknownServiceHandler rejects calls to services tracedown doesn't
+// implement. OTLP Arrow streams get an explicit error instead of a generic
+// unknown-service failure, since exporters with Arrow enabled try it first.
+func unknownServiceHandler(srv any, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	if isArrowMethod(method) {
+		log.Printf("gRPC: Rejected OTLP Arrow stream %s: Arrow is not supported", method)
+		return status.Error(codes.Unimplemented, arrowUnsupportedMessage)
+	}
+	return status.Errorf(codes.Unimplemented, "unknown service or method %s", method)
+}
+
+// isArrowMethod reports whether a gRPC method belongs to an OTLP Arrow service,
+// e.g. /opentelemetry.proto.experimental.arrow.v1.ArrowTracesService/ArrowTraces
+func isArrowMethod(method string) bool {
+	return strings.Contains(strings.ToLower(method), ".arrow.")
+}
+
+// isArrowContentType reports whether an HTTP request body is Arrow encoded
+func isArrowContentType(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "arrow")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckAssertions evaluates the CI assertions configured with -fail-on-error,
+// -fail-on-slow and -require-span against the stored traces, returning one
+// message per violation
+func (s *TraceStorage) CheckAssertions(config *Config) []string {
+	if !config.FailOnError && config.FailOnSlow <= 0 && len(config.RequireSpans) == 0 {
+		return nil
+	}
+
+	traces := groupTraces(s.Snapshot().traces)
+	var failures []string
+	for _, ti := range traces {
+		if config.FailOnError && ti.hasError() {
+			failures = append(failures, fmt.Sprintf("trace %s (%s) has errors", ti.traceID, ti.getRootSpanName()))
+		}
+		if config.FailOnSlow > 0 && ti.getDuration() > config.FailOnSlow {
+			failures = append(failures, fmt.Sprintf("trace %s (%s) took %v, over the %v limit",
+				ti.traceID, ti.getRootSpanName(), ti.getDuration(), config.FailOnSlow))
+		}
+		if missing := ti.missingRequiredSpans(config); len(missing) > 0 {
+			failures = append(failures, fmt.Sprintf("trace %s (%s) is missing required spans: %s",
+				ti.traceID, ti.getRootSpanName(), strings.Join(missing, ", ")))
+		}
+	}
+	return failures
+}
+
+// missingRequiredSpans returns the -require-span names that don't appear in
+// the trace. Traces whose root span doesn't match -require-span-root aren't
+// checked.
+func (ti *traceInfo) missingRequiredSpans(config *Config) []string {
+	if len(config.RequireSpans) == 0 {
+		return nil
+	}
+	if config.RequireSpanRoot != "" && !strings.Contains(ti.getRootSpanName(), config.RequireSpanRoot) {
+		return nil
+	}
+
+	present := make(map[string]bool, len(ti.spans))
+	for _, si := range ti.spans {
+		present[si.span.Name()] = true
+	}
+
+	var missing []string
+	for _, name := range config.RequireSpans {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// countIncompleteTraces returns how many traces are missing required spans
+func countIncompleteTraces(traces []*traceInfo, config *Config) int {
+	count := 0
+	for _, ti := range traces {
+		if len(ti.missingRequiredSpans(config)) > 0 {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Thresholds for flagging high-cardinality span attributes: keys seen on at
+// least minCardinalitySpans spans whose values are distinct at least
+// highCardinalityRatio of the time
+const (
+	minCardinalitySpans  = 20
+	highCardinalityRatio = 0.9
+)
+
+// attributeCardinality counts how many spans carry an attribute key and how
+// many distinct values it takes
+type attributeCardinality struct {
+	key      string
+	spans    int
+	distinct int
+	example  string
+}
+
+func (a attributeCardinality) ratio() float64 {
+	return float64(a.distinct) / float64(a.spans)
+}
+
+// findHighCardinalityAttributes returns span attribute keys whose values are
+// nearly always distinct, such as full URLs with query strings, sorted by
+// descending distinct count
+func findHighCardinalityAttributes(traces []*traceInfo) []attributeCardinality {
+	values := make(map[string]map[string]bool)
+	spans := make(map[string]int)
+	examples := make(map[string]string)
+	for _, ti := range traces {
+		for _, si := range ti.spans {
+			for _, attr := range spanAttributes(si.span.Attributes(), false) {
+				value := attr.value.AsString()
+				if values[attr.key] == nil {
+					values[attr.key] = make(map[string]bool)
+					examples[attr.key] = value
+				}
+				values[attr.key][value] = true
+				spans[attr.key]++
+			}
+		}
+	}
+
+	var flagged []attributeCardinality
+	for key, count := range spans {
+		card := attributeCardinality{key: key, spans: count, distinct: len(values[key]), example: examples[key]}
+		if count >= minCardinalitySpans && card.ratio() >= highCardinalityRatio {
+			flagged = append(flagged, card)
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool {
+		if flagged[i].distinct != flagged[j].distinct {
+			return flagged[i].distinct > flagged[j].distinct
+		}
+		return flagged[i].key < flagged[j].key
+	})
+	return flagged
+}
+
+// writeHighCardinalityAttributes lists likely high-cardinality attributes,
+// which bloat storage and usually point at an instrumentation mistake
+func writeHighCardinalityAttributes(f io.Writer, traces []*traceInfo, config *Config) {
+	flagged := findHighCardinalityAttributes(traces)
+	if len(flagged) == 0 {
+		return
+	}
+
+	fmt.Fprintf(f, "## ⚠️ High-Cardinality Attributes\n\n")
+	fmt.Fprintf(f, "These attribute keys have a distinct value on at least %.0f%% of the spans carrying them. Unless they're IDs by design, consider normalizing them (e.g. use `http.route` instead of the full URL).\n\n", highCardinalityRatio*100)
+	table := newMarkdownTable("Attribute", "Spans", "Distinct Values", "Distinct %", "Example")
+	for _, card := range flagged {
+		table.addRow(codeSpan(card.key), fmt.Sprintf("%d", card.spans), fmt.Sprintf("%d", card.distinct),
+			fmt.Sprintf("%.0f%%", card.ratio()*100), "`"+truncateText(card.example, 60)+"`")
+	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// reportTraces groups the snapshot's spans into the traces a report shows,
+// applying -split-collisions and -min-spans, and returns how many traces
+// -min-spans filtered out
+func (s *storageSnapshot) reportTraces(config *Config) ([]*traceInfo, int) {
+	traces := groupTraces(s.traces)
+	if config.SplitCollisions {
+		traces = splitCollisions(traces)
+	}
+	return filterMinSpans(traces, config.MinSpans)
+}
+
+// splitCollisions separates traces whose ID was reused by unrelated requests,
+// e.g. by a poorly seeded ID generator or a replay. A trace is split when its
+// spans form several trees that each have their own root, or else when its
+// span trees fall into time ranges that don't overlap; each becomes a trace
+// of its own, named by a part suffix like "-a" and "-b".
+func splitCollisions(traces []*traceInfo) []*traceInfo {
+	result := make([]*traceInfo, 0, len(traces))
+	for _, ti := range traces {
+		result = append(result, splitTrace(ti)...)
+	}
+	return result
+}
+
+// splitTrace returns the parts of a trace with several rooted span trees or
+// several disjoint time ranges, or the trace itself when it has neither
+func splitTrace(ti *traceInfo) []*traceInfo {
+	// Union every span with its parent to find the separate span trees
+	parent := make([]int, len(ti.spans))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	byID := make(map[pcommon.SpanID]int)
+	for i, si := range ti.spans {
+		if !si.span.SpanID().IsEmpty() {
+			byID[si.span.SpanID()] = i
+		}
+	}
+	for i, si := range ti.spans {
+		if p, ok := byID[si.span.ParentSpanID()]; ok && !si.span.ParentSpanID().IsEmpty() {
+			parent[find(i)] = find(p)
+		}
+	}
+
+	// Trees containing a parentless span are separate requests; the rest
+	// are orphans whose parent never arrived
+	rooted := make(map[int]bool)
+	for i, si := range ti.spans {
+		if si.span.ParentSpanID().IsEmpty() {
+			rooted[find(i)] = true
+		}
+	}
+	if len(rooted) < 2 {
+		clusters, count := timeClusters(ti, find)
+		if count < 2 {
+			return []*traceInfo{ti}
+		}
+		parts := newTraceParts(ti, count)
+		for i, si := range ti.spans {
+			part := parts[clusters[find(i)]]
+			part.spans = append(part.spans, si)
+		}
+		return finishTraceParts(parts)
+	}
+
+	partOf := make(map[int]int)
+	for i := range ti.spans {
+		if tree := find(i); rooted[tree] {
+			if _, ok := partOf[tree]; !ok {
+				partOf[tree] = len(partOf)
+			}
+		}
+	}
+	parts := newTraceParts(ti, len(partOf))
+	for i, si := range ti.spans {
+		if n, ok := partOf[find(i)]; ok {
+			parts[n].spans = append(parts[n].spans, si)
+		}
+	}
+
+	// Orphans join the part that was running when they started
+	for i, si := range ti.spans {
+		if tree := find(i); !rooted[tree] {
+			part := closestPart(parts, si.span.StartTimestamp())
+			part.spans = append(part.spans, si)
+		}
+	}
+	return finishTraceParts(parts)
+}
+
+// timeClusters groups a trace's span trees (keyed by their find root) into
+// runs of overlapping time ranges, numbered in start order, and returns the
+// grouping and how many runs there are
+func timeClusters(ti *traceInfo, find func(int) int) (map[int]int, int) {
+	type timeRange struct {
+		tree       int
+		start, end pcommon.Timestamp
+	}
+	ranges := make(map[int]*timeRange)
+	var trees []*timeRange
+	for i, si := range ti.spans {
+		tree := find(i)
+		start, end := si.span.StartTimestamp(), max(si.span.EndTimestamp(), si.span.StartTimestamp())
+		r, ok := ranges[tree]
+		if !ok {
+			r = &timeRange{tree: tree, start: start, end: end}
+			ranges[tree] = r
+			trees = append(trees, r)
+			continue
+		}
+		r.start, r.end = min(r.start, start), max(r.end, end)
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].start < trees[j].start })
+
+	clusters := make(map[int]int, len(trees))
+	count := 0
+	var clusterEnd pcommon.Timestamp
+	for _, r := range trees {
+		if count == 0 || r.start > clusterEnd {
+			count++
+			clusterEnd = r.end
+		}
+		clusterEnd = max(clusterEnd, r.end)
+		clusters[r.tree] = count - 1
+	}
+	return clusters, count
+}
+
+// newTraceParts returns count empty parts of a trace sharing its arrival metadata
+func newTraceParts(ti *traceInfo, count int) []*traceInfo {
+	parts := make([]*traceInfo, count)
+	for n := range parts {
+		parts[n] = &traceInfo{
+			traceID:      ti.traceID,
+			firstArrival: ti.firstArrival,
+			lastArrival:  ti.lastArrival,
+			batches:      ti.batches,
+		}
+	}
+	return parts
+}
+
+// finishTraceParts sorts each part's spans and names the parts
+func finishTraceParts(parts []*traceInfo) []*traceInfo {
+	for n, part := range parts {
+		sort.Slice(part.spans, func(i, j int) bool {
+			return spanBefore(part.spans[i].span, part.spans[j].span)
+		})
+		part.collisionPart = collisionSuffix(n)
+		part.collisionParts = len(parts)
+	}
+	return parts
+}
+
+// collisionSuffix names the nth part of a split trace: a to z, then numbers
+func collisionSuffix(n int) string {
+	if n < 26 {
+		return string(rune('a' + n))
+	}
+	return fmt.Sprintf("%d", n+1)
+}
+
+// closestPart returns the part whose time range contains ts, or else the
+// one starting nearest to it
+func closestPart(parts []*traceInfo, ts pcommon.Timestamp) *traceInfo {
+	var best *traceInfo
+	var bestDistance uint64
+	for _, part := range parts {
+		start := uint64(part.getEarliestTime())
+		end := start + uint64(part.getDuration())
+		var distance uint64
+		switch {
+		case uint64(ts) < start:
+			distance = start - uint64(ts)
+		case uint64(ts) > end:
+			distance = uint64(ts) - end
+		}
+		if best == nil || distance < bestDistance {
+			best, bestDistance = part, distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// setSpanTimes moves a test trace's span to start and end at the given milliseconds
+func setSpanTimes(ti *traceInfo, index int, startMs, endMs int) {
+	span := ti.spans[index].span
+	span.SetStartTimestamp(pcommon.Timestamp(startMs * 1_000_000))
+	span.SetEndTimestamp(pcommon.Timestamp(endMs * 1_000_000))
+}
+
+func TestSplitTrace(t *testing.T) {
+	partNames := func(ti *traceInfo) map[string]string {
+		parts := splitTrace(ti)
+		names := make(map[string]string)
+		for _, part := range parts {
+			for _, si := range part.spans {
+				names[si.span.Name()] = part.collisionPart
+			}
+		}
+		return names
+	}
+
+	t.Run("several roots", func(t *testing.T) {
+		ti := newTestTrace(testSpan{1, 0, "first"}, testSpan{2, 0, "second"}, testSpan{3, 1, "child"})
+		names := partNames(ti)
+		if names["first"] != "a" || names["child"] != "a" || names["second"] != "b" {
+			t.Errorf("parts = %v, want first and child in a, second in b", names)
+		}
+	})
+
+	t.Run("disjoint time ranges", func(t *testing.T) {
+		// Neither request's root arrived, but they ran a second apart
+		ti := newTestTrace(testSpan{1, 8, "first"}, testSpan{2, 1, "first child"}, testSpan{3, 9, "second"})
+		setSpanTimes(ti, 0, 0, 10)
+		setSpanTimes(ti, 1, 2, 8)
+		setSpanTimes(ti, 2, 1000, 1010)
+		names := partNames(ti)
+		if names["first"] != "a" || names["first child"] != "a" || names["second"] != "b" {
+			t.Errorf("parts = %v, want first and first child in a, second in b", names)
+		}
+	})
+
+	t.Run("overlapping orphans stay together", func(t *testing.T) {
+		ti := newTestTrace(testSpan{1, 0, "root"}, testSpan{2, 9, "orphan"})
+		if parts := splitTrace(ti); len(parts) != 1 || parts[0] != ti {
+			t.Errorf("a single request was split into %d parts", len(parts))
+		}
+	})
+}
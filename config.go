@@ -3,27 +3,132 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"net"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 // Config holds all configuration for the tracedown server
 type Config struct {
 	// Server configuration
-	Host      string
-	GRPCPort  int
-	HTTPPort  int
-	BindAll   bool
+	Host                  string
+	GRPCPort              int
+	HTTPPort              int
+	BindAll               bool
+	GRPCUnix              string
+	HTTPUnix              string
+	SinglePort            int
+	SenderSummaryInterval time.Duration
+	Follow                time.Duration
+	AuthToken             string
+	AllowPartial          bool
+	ForwardTo             string
+	MaxConcurrentHTTP     int
+	H2C                   bool
+	InputFiles            []string
+	Stdin                 bool
+	InputFormat           string
 
 	// Storage limits
-	MaxTraces      int
-	MaxMemoryMB    int
-	TraceExpiration time.Duration
+	MaxTraces              int
+	MaxMemoryMB            int
+	MemoryHeadroomMB       int
+	PerServiceMemoryMB     int
+	TraceExpiration        time.Duration
+	TraceTimeout           time.Duration
+	MaxSpansStoredPerTrace int
+	ReportRealMemory       bool
+	DropSpanMatching       []string
 
 	// Output configuration
-	OutputFile     string
-	SummaryMode    bool
-	MaxSpansPerTrace int
+	OutputFiles         []string
+	ExportOTLP          string
+	CSVFile             string
+	ReportTitle         string
+	ReportNote          string
+	Rotate              int
+	SummaryMode         bool
+	StatsOnly           bool
+	MaxSpansPerTrace    int
+	MinSpans            int
+	SpanCountWarn       int
+	Pretty              bool
+	CompactJSON         bool
+	NoTimeline          bool
+	NoTables            bool
+	FlattenAttrs        bool
+	DumpOnPanic         bool
+	Debug               bool
+	Anonymize           bool
+	CheckSemconv        bool
+	SplitCollisions     bool
+	CollapseTraces      bool
+	CriticalSpanPattern string
+	ReceivedFormat      string
+	SpanTimestamps      bool
+	DetailsMode         string
+	HideInternalStats   bool
+	Timezone            string
+	BaselineFile        string
+	SampleRate          float64
+	TraceLabelAttrs     string
+	OverviewLabels      string
+	GroupBy             string
+	TraceNameAttr       string
+	ServiceNameFallback string
+	ServiceNameSpanAttr string
+	SpanKinds           string
+	TermWidth           int
+	TimelineEvents      bool
+	Sequence            bool
+	LogScale            bool
+	View                string
+	DurationPrecision   int
+	MaxEventsPerSpan    int
+	Columns             string
+	FailOnError         bool
+	FailOnSlow          time.Duration
+	RequireSpans        []string
+	RequireSpanRoot     string
+	NumericAttrStats    []string
+
+	// baseline holds operation statistics loaded from BaselineFile
+	baseline map[string]*operationStats
+
+	// traceLabelAttrs is TraceLabelAttrs split into attribute keys
+	traceLabelAttrs []string
+	// overviewLabels is OverviewLabels split into attribute keys
+	overviewLabels []string
+	// groupByKey is the attribute key parsed from GroupBy
+	groupByKey string
+	// location is Timezone loaded for formatting wall-clock times
+	location *time.Location
+	// criticalSpanPattern is the compiled CriticalSpanPattern
+	criticalSpanPattern *regexp.Regexp
+
+	// serviceNameKeys is ServiceNameFallback split into resource attribute keys
+	serviceNameKeys []string
+
+	// dropRules are the parsed DropSpanMatching rules
+	dropRules []spanMatchRule
+
+	// spanKinds is SpanKinds parsed into the kinds to render (nil = all)
+	spanKinds map[ptrace.SpanKind]bool
+
+	// spanColumns is Columns parsed into span table column names, in order
+	spanColumns []string
+
+	// anonymizer maps IDs to pseudonyms when Anonymize is set
+	anonymizer *idAnonymizer
+
+	// explicitFlags records which flags were set on the command line
+	explicitFlags map[string]bool
 }
 
 // NewConfig creates a configuration from command line flags
@@ -38,19 +143,90 @@ func NewConfig() *Config {
 	flag.IntVar(&cfg.GRPCPort, "grpc-port", 4317, "Port for gRPC OTLP endpoint")
 	flag.IntVar(&cfg.HTTPPort, "http-port", 4318, "Port for HTTP OTLP endpoint")
 	flag.BoolVar(&cfg.BindAll, "bind-all", false, "Bind to all network interfaces (0.0.0.0) - WARNING: exposes unauthenticated endpoint")
+	flag.StringVar(&cfg.GRPCUnix, "grpc-unix", "", "Unix socket path for the gRPC OTLP endpoint (replaces host/port)")
+	flag.StringVar(&cfg.HTTPUnix, "http-unix", "", "Unix socket path for the HTTP OTLP endpoint (replaces host/port)")
+	flag.IntVar(&cfg.SinglePort, "single-port", 0, "Serve OTLP/gRPC and OTLP/HTTP on this one port, detecting the protocol per connection (0 = separate ports)")
+	flag.StringVar(&cfg.AuthToken, "auth-token", "", "Require this bearer token on OTLP ingest and API requests (default: no authentication)")
+	flag.BoolVar(&cfg.AllowPartial, "allow-partial", false, "Keep running if only one of the gRPC and HTTP endpoints can bind")
+	flag.IntVar(&cfg.MaxConcurrentHTTP, "max-concurrent-http", 0, "Maximum OTLP/HTTP export requests handled at once; extra requests get 503 so exporters retry later (0 = unlimited)")
+	flag.StringVar(&cfg.ForwardTo, "forward-to", "", "Also forward every received batch to this downstream OTLP gRPC endpoint (host:port)")
+	flag.DurationVar(&cfg.SenderSummaryInterval, "sender-summary-interval", 5*time.Minute, "How often to log a summary of connected senders (0 = only at shutdown)")
+	flag.DurationVar(&cfg.Follow, "follow", 0, "Log the traces and errors received since the last update at this interval, e.g. 10s (0 = off)")
 
 	// Storage flags
 	flag.IntVar(&cfg.MaxTraces, "max-traces", 10000, "Maximum number of trace batches to store (0 = unlimited)")
 	flag.IntVar(&cfg.MaxMemoryMB, "max-memory-mb", 500, "Approximate maximum memory for traces in MB (0 = unlimited)")
+	flag.IntVar(&cfg.MemoryHeadroomMB, "memory-headroom-mb", 0, "Part of -max-memory-mb kept free for report generation; traces are evicted once storage reaches the rest")
+	flag.IntVar(&cfg.PerServiceMemoryMB, "per-service-memory-mb", 0, "Approximate maximum memory per service.name in MB; evicts that service's oldest traces first (0 = no per-service budget)")
 	flag.DurationVar(&cfg.TraceExpiration, "trace-expiration", 1*time.Hour, "Expire traces older than this duration (0 = no expiration)")
+	flag.DurationVar(&cfg.TraceTimeout, "trace-timeout", 0, "Consider a trace complete and log it once it receives no new spans for this duration; later spans within 10x the timeout don't re-open it (0 = disabled)")
+	flag.IntVar(&cfg.MaxSpansStoredPerTrace, "max-spans-stored-per-trace", 0, "Maximum spans stored per trace besides root and error spans (always kept); over it, spans are sampled evenly across the whole trace by a hash of their span ID, thinning already stored spans too (0 = unlimited)")
+	flag.Var((*stringList)(&cfg.DropSpanMatching), "drop-span-matching", "Drop spans (and their children in the same batch) whose name or attribute matches key=value before storage, e.g. http.route=/healthz; use name=... for span names; repeatable")
+	flag.BoolVar(&cfg.ReportRealMemory, "report-real-memory", false, "Log the Go runtime's actual heap use next to the estimated memory in the final statistics")
 
 	// Output flags
-	flag.StringVar(&cfg.OutputFile, "output", "traces.md", "Output markdown file path")
+	flag.IntVar(&cfg.Rotate, "rotate", 0, "Write each run's reports to timestamped files (traces-<timestamp>.md) and keep only the newest N per output across runs; -export-otlp is not rotated (0 = overwrite the output file)")
+	flag.StringVar(&cfg.ReportTitle, "report-title", "OpenTelemetry Traces Report", "Report heading")
+	flag.StringVar(&cfg.ReportNote, "report-note", "", "Free-text paragraph shown after the report overview, e.g. an incident ID or run context")
+	flag.BoolVar(&cfg.H2C, "h2c", false, "Also accept HTTP/2 cleartext (h2c) on the HTTP endpoint")
+	flag.BoolVar(&cfg.Stdin, "stdin", false, "Build the report from OTLP records read from stdin instead of listening, e.g. cat dump.pb | tracedown -stdin")
+	flag.StringVar(&cfg.InputFormat, "input-format", "proto", "Encoding of -stdin input: proto (one export request) or json (one or more export requests)")
+	flag.Var((*stringList)(&cfg.InputFiles), "input", "OTLP trace dump (.json or protobuf) to build the report from instead of listening; glob patterns allowed; repeatable")
+	flag.StringVar(&cfg.CSVFile, "csv", "", "Also write the per-span CSV report to this file, e.g. spans.csv, in addition to the -output reports (the default traces.md is kept)")
+	flag.StringVar(&cfg.ExportOTLP, "export-otlp", "", "Also write every retained trace, after filtering and sampling, to this file as one OTLP protobuf export request for replaying elsewhere")
+	flag.Var((*stringList)(&cfg.OutputFiles), "output", "Output file path, format inferred from extension (.md, .json, .html, .csv); repeatable (default \"traces.md\")")
 	flag.BoolVar(&cfg.SummaryMode, "summary", false, "Generate summary mode (limited span details)")
+	flag.BoolVar(&cfg.StatsOnly, "stats-only", false, "Write only the aggregate sections (overview, services, operation statistics, errors) to markdown reports, without per-trace detail")
 	flag.IntVar(&cfg.MaxSpansPerTrace, "max-spans-per-trace", 100, "Maximum spans to show per trace in summary mode (0 = unlimited)")
+	flag.StringVar(&cfg.BaselineFile, "baseline", "", "Previous JSON report to compare operation latencies against")
+	flag.Float64Var(&cfg.SampleRate, "sample-rate", 0, "Head sampling rate used by senders (e.g. 0.1) for spans without a tracestate probability; adds extrapolated counts to operation statistics (0 = not sampled)")
+	flag.BoolVar(&cfg.Pretty, "pretty", false, "Pad markdown table cells so columns line up in the raw file")
+	flag.BoolVar(&cfg.CompactJSON, "compact-json", false, "Write .json reports on a single line instead of indented")
+	flag.BoolVar(&cfg.NoTimeline, "no-timeline", false, "Omit the ASCII span timeline from each trace")
+	flag.BoolVar(&cfg.NoTables, "no-tables", false, "Omit the service info and span summary tables from each trace")
+	flag.StringVar(&cfg.GroupBy, "group-by", "", "Group markdown report sections by a span attribute instead of trace status, e.g. attr:session.id; traces without it are listed as ungrouped")
+	flag.StringVar(&cfg.OverviewLabels, "overview-labels", "", "Comma-separated root span or resource attributes whose values are counted across traces in the overview, e.g. http.route,rpc.method")
+	flag.StringVar(&cfg.TraceLabelAttrs, "trace-label-attrs", "", "Comma-separated root span attributes composed into the TOC operation label, e.g. http.request.method,http.route (default: root span name)")
+	flag.StringVar(&cfg.TraceNameAttr, "trace-name-attr", "", "Root span attribute used as the trace heading instead of the trace ID, e.g. order.id or http.target")
+	flag.StringVar(&cfg.ServiceNameFallback, "service-name-fallback", defaultServiceNameFallback, "Comma-separated resource attributes tried in order to name a span's service; \"unknown\" if none is set")
+	flag.IntVar(&cfg.TermWidth, "term-width", 0, "Fit ASCII timeline lines to this many columns, splitting the space between span names and duration bars (0 = fixed 50-char names and 24-char bars)")
+	flag.BoolVar(&cfg.TimelineEvents, "timeline-events", false, "Draw span event markers at their position within each span's timeline bar, with a legend of event names")
+	flag.StringVar(&cfg.ServiceNameSpanAttr, "service-name-span-attr", "", "Span attribute naming the service when none of the -service-name-fallback resource attributes is set, e.g. for Jaeger-origin data")
+	flag.IntVar(&cfg.MaxEventsPerSpan, "max-events-per-span", 10, "Maximum events listed per span in span details; exception events are kept first (0 = unlimited)")
+	flag.IntVar(&cfg.DurationPrecision, "duration-precision", -1, "Decimal places for timeline durations (-1 = 1 for µs/ms, 2 for s)")
+	flag.StringVar(&cfg.View, "view", "tree", "Span timeline layout: tree (nested by parent) or waterfall (start order, bars offset by start time)")
+	flag.BoolVar(&cfg.LogScale, "log-scale", false, "Scale timeline bars logarithmically so short spans stay visible in long traces")
+	flag.BoolVar(&cfg.Sequence, "sequence", false, "Add a Mermaid sequence diagram of cross-service calls (CLIENT to SERVER spans) to each markdown trace")
+	flag.StringVar(&cfg.SpanKinds, "span-kinds", "", "Comma-separated span kinds to show in timelines and span tables, e.g. server,client (default: all)")
+	flag.StringVar(&cfg.Columns, "columns", defaultSpanColumns, "Comma-separated span summary table columns, in order, from: "+strings.Join(spanColumnNames, ","))
+	flag.BoolVar(&cfg.FailOnError, "fail-on-error", false, "Exit non-zero at shutdown if any stored trace has an error span (report is still written)")
+	flag.DurationVar(&cfg.FailOnSlow, "fail-on-slow", 0, "Exit non-zero at shutdown if any stored trace takes longer than this (0 = disabled)")
+	flag.Var((*stringList)(&cfg.RequireSpans), "require-span", "Span name every trace must contain; violations are flagged in the report and fail the run; repeatable")
+	flag.Var((*stringList)(&cfg.NumericAttrStats), "numeric-attr-stats", "Numeric span attribute to aggregate (min/max/sum across spans) in each trace, e.g. alloc.bytes; a trailing * matches a prefix, e.g. process.runtime.*; repeatable")
+	flag.StringVar(&cfg.RequireSpanRoot, "require-span-root", "", "Only apply -require-span to traces whose root span name contains this")
+	flag.BoolVar(&cfg.HideInternalStats, "hide-internal-stats", false, "Leave collector stats (drops, filtering, arrival rate) out of the report overview, keeping only Generated, Total Traces and Error Rate, for reports shared externally")
+	flag.StringVar(&cfg.DetailsMode, "details-mode", "html", "How the markdown span table shows span details: html (inline, <br>-separated; GitHub and similar), plain (counts in the table, details as a bullet list below it) or none")
+	flag.BoolVar(&cfg.SpanTimestamps, "span-timestamps", false, "Show each span's wall-clock start and end time in the span details, for matching spans against application logs")
+	flag.StringVar(&cfg.Timezone, "timezone", "Local", "Time zone for wall-clock times in reports (-span-timestamps, absolute -received-format), e.g. UTC or Europe/Berlin")
+	flag.StringVar(&cfg.ReceivedFormat, "received-format", "relative", "How the TOC shows when each trace arrived: relative (\"12m ago\") or absolute")
+	flag.BoolVar(&cfg.Anonymize, "anonymize", false, "Replace trace and span IDs in reports with stable pseudonyms so reports can be shared without exposing real IDs")
+	flag.StringVar(&cfg.CriticalSpanPattern, "critical-span-pattern", "", "Regex for business-critical span names (e.g. 'payment|auth'); errors in matching spans are marked 🚨 and their traces listed first in the error table of contents")
+	flag.BoolVar(&cfg.CollapseTraces, "collapse-traces", false, "Wrap each trace in the markdown report in a collapsed <details> section, for navigating long reports in a browser")
+	flag.BoolVar(&cfg.SplitCollisions, "split-collisions", false, "Split traces whose ID was reused by unrelated requests (several separate root spans, or span trees whose time ranges don't overlap) into parts named <trace-id>-a, -b, ...")
+	flag.BoolVar(&cfg.CheckSemconv, "check-semconv", false, "Flag HTTP and database spans missing expected semantic-convention attributes (e.g. http.route on HTTP server spans)")
+	flag.BoolVar(&cfg.Debug, "debug", false, "Log per-batch details, eviction decisions and expiration scans")
+	flag.BoolVar(&cfg.DumpOnPanic, "dump-on-panic", false, "If a trace fails to render, replace it with a placeholder and save its raw OTLP protobuf next to the report instead of crashing")
+	flag.BoolVar(&cfg.FlattenAttrs, "flatten-attrs", false, "Expand nested map attributes into dotted keys instead of rendering them inline")
+	flag.IntVar(&cfg.SpanCountWarn, "span-count-warn", 0, "List traces with more than this many spans in a warning section (0 = disabled)")
+	flag.IntVar(&cfg.MinSpans, "min-spans", 0, "Skip traces with fewer than this many spans in the report (0 = keep all)")
 
 	flag.Parse()
 
+	cfg.explicitFlags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		cfg.explicitFlags[f.Name] = true
+	})
+
 	// Show version and exit if requested
 	if *showVersion {
 		fmt.Printf("tracedown version %s\n", version)
@@ -60,6 +236,31 @@ func NewConfig() *Config {
 		os.Exit(0)
 	}
 
+	for _, key := range strings.Split(cfg.TraceLabelAttrs, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.traceLabelAttrs = append(cfg.traceLabelAttrs, key)
+		}
+	}
+
+	for _, key := range strings.Split(cfg.OverviewLabels, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.overviewLabels = append(cfg.overviewLabels, key)
+		}
+	}
+
+	for _, key := range strings.Split(cfg.ServiceNameFallback, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.serviceNameKeys = append(cfg.serviceNameKeys, key)
+		}
+	}
+
+	if len(cfg.OutputFiles) == 0 {
+		cfg.OutputFiles = []string{"traces.md"}
+	}
+	if cfg.CSVFile != "" {
+		cfg.OutputFiles = append(cfg.OutputFiles, cfg.CSVFile)
+	}
+
 	// Apply bind-all override
 	if cfg.BindAll {
 		cfg.Host = "0.0.0.0"
@@ -68,14 +269,48 @@ func NewConfig() *Config {
 	return cfg
 }
 
+// OfflineInput reports whether the report is built from -input files or
+// -stdin rather than from received traces
+func (c *Config) OfflineInput() bool {
+	return len(c.InputFiles) > 0 || c.Stdin
+}
+
+// GRPCNetwork returns the network type for the gRPC listener
+func (c *Config) GRPCNetwork() string {
+	if c.GRPCUnix != "" {
+		return "unix"
+	}
+	return "tcp"
+}
+
 // GRPCAddr returns the full gRPC address to bind to
 func (c *Config) GRPCAddr() string {
-	return fmt.Sprintf("%s:%d", c.Host, c.GRPCPort)
+	if c.GRPCUnix != "" {
+		return c.GRPCUnix
+	}
+	if c.SinglePort > 0 {
+		return net.JoinHostPort(c.Host, strconv.Itoa(c.SinglePort))
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.GRPCPort))
+}
+
+// HTTPNetwork returns the network type for the HTTP listener
+func (c *Config) HTTPNetwork() string {
+	if c.HTTPUnix != "" {
+		return "unix"
+	}
+	return "tcp"
 }
 
 // HTTPAddr returns the full HTTP address to bind to
 func (c *Config) HTTPAddr() string {
-	return fmt.Sprintf("%s:%d", c.Host, c.HTTPPort)
+	if c.HTTPUnix != "" {
+		return c.HTTPUnix
+	}
+	if c.SinglePort > 0 {
+		return net.JoinHostPort(c.Host, strconv.Itoa(c.SinglePort))
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.HTTPPort))
 }
 
 // Validate checks if the configuration is valid
@@ -86,26 +321,237 @@ func (c *Config) Validate() error {
 	if c.HTTPPort < 1 || c.HTTPPort > 65535 {
 		return fmt.Errorf("invalid HTTP port: %d", c.HTTPPort)
 	}
-	if c.GRPCPort == c.HTTPPort {
+	if c.GRPCUnix != "" && c.explicitFlags["grpc-port"] {
+		return fmt.Errorf("-grpc-port and -grpc-unix cannot both be set")
+	}
+	if c.HTTPUnix != "" && c.explicitFlags["http-port"] {
+		return fmt.Errorf("-http-port and -http-unix cannot both be set")
+	}
+	if c.SinglePort != 0 {
+		if c.SinglePort < 1 || c.SinglePort > 65535 {
+			return fmt.Errorf("invalid single port: %d", c.SinglePort)
+		}
+		for _, name := range []string{"grpc-port", "http-port", "grpc-unix", "http-unix"} {
+			if c.explicitFlags[name] {
+				return fmt.Errorf("-single-port and -%s cannot both be set", name)
+			}
+		}
+	}
+	if c.SinglePort == 0 && c.GRPCUnix == "" && c.HTTPUnix == "" && c.GRPCPort == c.HTTPPort {
 		return fmt.Errorf("gRPC and HTTP ports cannot be the same: %d", c.GRPCPort)
 	}
+	if c.GRPCUnix != "" && c.GRPCUnix == c.HTTPUnix {
+		return fmt.Errorf("gRPC and HTTP unix sockets cannot be the same: %s", c.GRPCUnix)
+	}
+	if c.H2C && c.SinglePort > 0 {
+		// The connection sniffing that routes gRPC already speaks HTTP/2 to the
+		// client, so a second HTTP/2 server on the same connection can't reply
+		return fmt.Errorf("-h2c can't be used with -single-port")
+	}
+	if c.OfflineInput() && c.ForwardTo != "" {
+		return fmt.Errorf("-forward-to can't be used with -input or -stdin, which don't receive traces")
+	}
+	if c.InputFormat != "proto" && c.InputFormat != "json" {
+		return fmt.Errorf("invalid input format %q (must be proto or json)", c.InputFormat)
+	}
+	if c.Follow < 0 {
+		return fmt.Errorf("follow interval cannot be negative: %v", c.Follow)
+	}
+	if c.OfflineInput() && c.Follow > 0 {
+		return fmt.Errorf("-follow can't be used with -input or -stdin, which don't receive traces")
+	}
+	if c.Rotate < 0 {
+		return fmt.Errorf("rotate count cannot be negative: %d", c.Rotate)
+	}
+	if c.MaxConcurrentHTTP < 0 {
+		return fmt.Errorf("max concurrent HTTP requests cannot be negative: %d", c.MaxConcurrentHTTP)
+	}
 	if c.MaxMemoryMB < 0 {
 		return fmt.Errorf("max memory cannot be negative: %d", c.MaxMemoryMB)
 	}
+	if c.MemoryHeadroomMB < 0 {
+		return fmt.Errorf("memory headroom cannot be negative: %d", c.MemoryHeadroomMB)
+	}
+	if c.MemoryHeadroomMB > 0 && c.MaxMemoryMB == 0 {
+		return fmt.Errorf("-memory-headroom-mb requires -max-memory-mb")
+	}
+	if c.MaxMemoryMB > 0 && c.MemoryHeadroomMB >= c.MaxMemoryMB {
+		return fmt.Errorf("memory headroom (%d MB) must be less than max memory (%d MB)", c.MemoryHeadroomMB, c.MaxMemoryMB)
+	}
+	if c.PerServiceMemoryMB < 0 {
+		return fmt.Errorf("per-service memory cannot be negative: %d", c.PerServiceMemoryMB)
+	}
+	if c.TraceTimeout < 0 {
+		return fmt.Errorf("trace timeout cannot be negative: %v", c.TraceTimeout)
+	}
+	if c.MaxSpansStoredPerTrace < 0 {
+		return fmt.Errorf("max spans stored per trace cannot be negative: %d", c.MaxSpansStoredPerTrace)
+	}
 	if c.MaxTraces < 0 {
 		return fmt.Errorf("max traces cannot be negative: %d", c.MaxTraces)
 	}
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	c.location = location
+	if c.ReceivedFormat != "relative" && c.ReceivedFormat != "absolute" {
+		return fmt.Errorf("invalid received format %q (expected relative or absolute)", c.ReceivedFormat)
+	}
+	if c.SpanCountWarn < 0 {
+		return fmt.Errorf("span count warning threshold cannot be negative: %d", c.SpanCountWarn)
+	}
+	if c.DetailsMode != "html" && c.DetailsMode != "plain" && c.DetailsMode != "none" {
+		return fmt.Errorf("invalid details mode %q (must be html, plain or none)", c.DetailsMode)
+	}
+	if c.View != "tree" && c.View != "waterfall" {
+		return fmt.Errorf("invalid view %q (must be tree or waterfall)", c.View)
+	}
+	if c.View == "waterfall" && c.LogScale {
+		// Bars positioned by start time only line up on a linear scale
+		return fmt.Errorf("-log-scale can't be used with -view waterfall")
+	}
+	if c.DurationPrecision < -1 || c.DurationPrecision > 9 {
+		return fmt.Errorf("duration precision must be between 0 and 9 (or -1 for the default): %d", c.DurationPrecision)
+	}
+	if c.MinSpans < 0 {
+		return fmt.Errorf("min spans cannot be negative: %d", c.MinSpans)
+	}
+	if c.RequireSpanRoot != "" && len(c.RequireSpans) == 0 {
+		return fmt.Errorf("-require-span-root needs at least one -require-span")
+	}
+	for _, key := range c.NumericAttrStats {
+		if strings.TrimSpace(key) == "" || key == "*" {
+			return fmt.Errorf("invalid -numeric-attr-stats key %q", key)
+		}
+	}
+	if c.FailOnSlow < 0 {
+		return fmt.Errorf("fail-on-slow cannot be negative: %v", c.FailOnSlow)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be between 0 and 1: %v", c.SampleRate)
+	}
+	if len(c.serviceNameKeys) == 0 {
+		return fmt.Errorf("-service-name-fallback must list at least one resource attribute")
+	}
+	if c.MaxEventsPerSpan < 0 {
+		return fmt.Errorf("max events per span cannot be negative: %d", c.MaxEventsPerSpan)
+	}
+	if c.TermWidth < 0 {
+		return fmt.Errorf("term width cannot be negative: %d", c.TermWidth)
+	}
+	if c.NoTimeline && c.NoTables {
+		return fmt.Errorf("-no-timeline and -no-tables cannot both be set")
+	}
+	c.dropRules = nil
+	for _, rule := range c.DropSpanMatching {
+		parsed, err := parseSpanMatchRule(rule)
+		if err != nil {
+			return err
+		}
+		c.dropRules = append(c.dropRules, parsed)
+	}
+	if c.SpanKinds != "" {
+		kinds, err := parseSpanKinds(c.SpanKinds)
+		if err != nil {
+			return err
+		}
+		c.spanKinds = kinds
+	}
+	if c.Anonymize && c.anonymizer == nil {
+		anonymizer, err := newIDAnonymizer()
+		if err != nil {
+			return fmt.Errorf("failed to initialize -anonymize: %w", err)
+		}
+		c.anonymizer = anonymizer
+	}
+	if c.CriticalSpanPattern != "" {
+		pattern, err := regexp.Compile(c.CriticalSpanPattern)
+		if err != nil {
+			return fmt.Errorf("invalid -critical-span-pattern: %w", err)
+		}
+		c.criticalSpanPattern = pattern
+	}
+	if c.GroupBy != "" {
+		key, err := parseGroupBy(c.GroupBy)
+		if err != nil {
+			return err
+		}
+		c.groupByKey = key
+	}
+	columns, err := parseSpanColumns(c.Columns)
+	if err != nil {
+		return err
+	}
+	c.spanColumns = columns
+	if c.DetailsMode == "none" && len(columns) == 1 && columns[0] == "details" {
+		return fmt.Errorf("-details-mode none leaves no span table columns")
+	}
+	if c.CSVFile != "" && !strings.HasSuffix(strings.ToLower(c.CSVFile), ".csv") {
+		return fmt.Errorf("-csv file must have a .csv extension: %q", c.CSVFile)
+	}
+	seen := make(map[string]bool)
+	for _, path := range c.outputPaths() {
+		if _, err := c.formatFor(path); err != nil {
+			return err
+		}
+		if seen[path] {
+			return fmt.Errorf("output file specified more than once: %s", path)
+		}
+		seen[path] = true
+		if err := checkOutputWritable(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadBaseline reads the baseline JSON report, if one is configured
+func (c *Config) LoadBaseline() error {
+	if c.BaselineFile == "" {
+		return nil
+	}
+	baseline, err := loadBaseline(c.BaselineFile)
+	if err != nil {
+		return err
+	}
+	c.baseline = baseline
 	return nil
 }
 
 // PrintConfig logs the current configuration
 func (c *Config) PrintConfig() {
 	fmt.Println("Configuration:")
-	fmt.Printf("  Server:\n")
-	fmt.Printf("    gRPC endpoint: %s\n", c.GRPCAddr())
-	fmt.Printf("    HTTP endpoint: %s\n", c.HTTPAddr())
-	if c.Host == "0.0.0.0" {
-		fmt.Printf("    ⚠️  WARNING: Binding to all interfaces (unauthenticated)\n")
+	if c.OfflineInput() {
+		fmt.Printf("  Input (no servers started):\n")
+		if len(c.InputFiles) > 0 {
+			fmt.Printf("    Files: %s\n", strings.Join(c.InputFiles, ", "))
+		}
+		if c.Stdin {
+			fmt.Printf("    Stdin: %s\n", c.InputFormat)
+		}
+	} else {
+		fmt.Printf("  Server:\n")
+		fmt.Printf("    gRPC endpoint: %s://%s\n", c.GRPCNetwork(), c.GRPCAddr())
+		fmt.Printf("    HTTP endpoint: %s://%s\n", c.HTTPNetwork(), c.HTTPAddr())
+		if (c.Host == "0.0.0.0" || c.Host == "::") && c.AuthToken == "" {
+			fmt.Printf("    ⚠️  WARNING: Binding to all interfaces (unauthenticated)\n")
+		}
+		if c.AuthToken != "" {
+			fmt.Printf("    Authentication: bearer token required\n")
+		}
+		if c.H2C {
+			fmt.Printf("    HTTP/2 cleartext (h2c): enabled\n")
+		}
+		if c.MaxConcurrentHTTP > 0 {
+			fmt.Printf("    Max concurrent HTTP exports: %d\n", c.MaxConcurrentHTTP)
+		}
+		if c.ForwardTo != "" {
+			fmt.Printf("    Forwarding to: %s (OTLP gRPC)\n", c.ForwardTo)
+		}
+		if c.Follow > 0 {
+			fmt.Printf("    Follow: new traces and errors every %v\n", c.Follow)
+		}
 	}
 	fmt.Printf("  Storage Limits:\n")
 	if c.MaxTraces > 0 {
@@ -115,21 +561,220 @@ func (c *Config) PrintConfig() {
 	}
 	if c.MaxMemoryMB > 0 {
 		fmt.Printf("    Max memory: ~%d MB\n", c.MaxMemoryMB)
+		if c.MemoryHeadroomMB > 0 {
+			fmt.Printf("    Report headroom: %d MB (traces limited to ~%d MB)\n", c.MemoryHeadroomMB, c.MaxMemoryMB-c.MemoryHeadroomMB)
+		}
 	} else {
 		fmt.Printf("    Max memory: unlimited\n")
 	}
+	if c.PerServiceMemoryMB > 0 {
+		fmt.Printf("    Max memory per service: ~%d MB\n", c.PerServiceMemoryMB)
+	}
 	if c.TraceExpiration > 0 {
 		fmt.Printf("    Trace expiration: %v\n", c.TraceExpiration)
 	} else {
 		fmt.Printf("    Trace expiration: disabled\n")
 	}
+	if c.MaxSpansStoredPerTrace > 0 {
+		fmt.Printf("    Max spans stored per trace: %d\n", c.MaxSpansStoredPerTrace)
+	}
+	if len(c.DropSpanMatching) > 0 {
+		fmt.Printf("    Drop spans matching: %s\n", strings.Join(c.DropSpanMatching, ", "))
+	}
+	if c.TraceTimeout > 0 {
+		fmt.Printf("    Trace completion timeout: %v\n", c.TraceTimeout)
+	}
 	fmt.Printf("  Output:\n")
-	fmt.Printf("    File: %s\n", c.OutputFile)
+	for _, path := range c.outputPaths() {
+		format, _ := c.formatFor(path)
+		fmt.Printf("    File: %s (%s)\n", path, format.name)
+	}
+	if c.Rotate > 0 {
+		fmt.Printf("    Rotation: timestamped files, keeping the newest %d\n", c.Rotate)
+	}
 	fmt.Printf("    Mode: ")
-	if c.SummaryMode {
+	if c.StatsOnly {
+		fmt.Println("stats only")
+	} else if c.SummaryMode {
 		fmt.Printf("summary (max %d spans per trace)\n", c.MaxSpansPerTrace)
 	} else {
 		fmt.Println("detailed")
 	}
+	if c.MinSpans > 0 {
+		fmt.Printf("    Min spans per trace: %d\n", c.MinSpans)
+	}
+	if c.SpanCountWarn > 0 {
+		fmt.Printf("    Warn on traces over: %d spans\n", c.SpanCountWarn)
+	}
+	if len(c.traceLabelAttrs) > 0 {
+		fmt.Printf("    Trace labels: %s\n", strings.Join(c.traceLabelAttrs, ", "))
+	}
+	if c.groupByKey != "" {
+		fmt.Printf("    Group by: %s\n", c.groupByKey)
+	}
+	if len(c.overviewLabels) > 0 {
+		fmt.Printf("    Overview labels: %s\n", strings.Join(c.overviewLabels, ", "))
+	}
+	if c.ServiceNameFallback != defaultServiceNameFallback {
+		fmt.Printf("    Service name from: %s\n", strings.Join(c.serviceNameKeys, ", "))
+	}
+	if c.ServiceNameSpanAttr != "" {
+		fmt.Printf("    Service name span attribute: %s\n", c.ServiceNameSpanAttr)
+	}
+	if c.TermWidth > 0 {
+		layout := timelineLayoutFor(c)
+		fmt.Printf("    Timeline width: %d columns (%d-char names, %d-char bars)\n", c.TermWidth, layout.nameWidth, layout.barWidth)
+	}
+	if c.SpanKinds != "" {
+		fmt.Printf("    Span kinds: %s\n", c.SpanKinds)
+	}
+	if c.View != "tree" {
+		fmt.Printf("    Timeline view: %s\n", c.View)
+	}
+	if c.DurationPrecision >= 0 {
+		fmt.Printf("    Duration precision: %d decimal places\n", c.DurationPrecision)
+	}
+	if c.HideInternalStats {
+		fmt.Printf("    Internal stats: hidden\n")
+	}
+	if c.DetailsMode != "html" {
+		fmt.Printf("    Span details: %s\n", c.DetailsMode)
+	}
+	if c.SpanTimestamps {
+		fmt.Printf("    Span timestamps: shown\n")
+	}
+	if c.Timezone != "Local" {
+		fmt.Printf("    Time zone: %s\n", c.Timezone)
+	}
+	if c.CriticalSpanPattern != "" {
+		fmt.Printf("    Critical spans: %s\n", c.CriticalSpanPattern)
+	}
+	if c.CollapseTraces {
+		fmt.Printf("    Trace sections: collapsed\n")
+	}
+	if c.SplitCollisions {
+		fmt.Printf("    Split reused trace IDs: enabled\n")
+	}
+	if c.CheckSemconv {
+		fmt.Printf("    Semantic convention checks: enabled\n")
+	}
+	if c.Anonymize {
+		fmt.Printf("    Trace and span IDs: anonymized\n")
+	}
+	if c.Columns != defaultSpanColumns {
+		fmt.Printf("    Span table columns: %s\n", strings.Join(c.spanColumns, ", "))
+	}
+	if c.CompactJSON {
+		fmt.Printf("    JSON output: compact\n")
+	}
+	if c.Debug {
+		fmt.Printf("    Debug logging: enabled\n")
+	}
+	if c.SampleRate > 0 {
+		fmt.Printf("    Sample rate: %v\n", c.SampleRate)
+	}
+	if c.FailOnError {
+		fmt.Printf("    Fail on error: enabled\n")
+	}
+	if c.FailOnSlow > 0 {
+		fmt.Printf("    Fail on slow: traces over %v\n", c.FailOnSlow)
+	}
+	if len(c.RequireSpans) > 0 {
+		fmt.Printf("    Required spans: %s", strings.Join(c.RequireSpans, ", "))
+		if c.RequireSpanRoot != "" {
+			fmt.Printf(" (root spans containing %q)", c.RequireSpanRoot)
+		}
+		fmt.Println()
+	}
+	if len(c.NumericAttrStats) > 0 {
+		fmt.Printf("    Numeric attribute stats: %s\n", strings.Join(c.NumericAttrStats, ", "))
+	}
+	if c.BaselineFile != "" {
+		fmt.Printf("    Baseline: %s (%d operations)\n", c.BaselineFile, len(c.baseline))
+	}
 	fmt.Println()
 }
+
+// debugf logs a message only when -debug is set
+func (c *Config) debugf(format string, args ...any) {
+	if c.Debug {
+		log.Printf("Debug: "+format, args...)
+	}
+}
+
+// storageLimitBytes is how much memory stored traces may use: MaxMemoryMB
+// less the headroom reserved for rendering reports
+func (c *Config) storageLimitBytes() int64 {
+	return int64(c.MaxMemoryMB-c.MemoryHeadroomMB) * 1024 * 1024
+}
+
+// showSpanKind reports whether spans of kind should be rendered in detail
+func (c *Config) showSpanKind(kind ptrace.SpanKind) bool {
+	return c.spanKinds == nil || c.spanKinds[kind]
+}
+
+// parseSpanKinds parses a comma-separated list of span kind names such as
+// "server,client"; the "SPAN_KIND_" prefix is optional and case is ignored
+func parseSpanKinds(list string) (map[ptrace.SpanKind]bool, error) {
+	known := map[string]ptrace.SpanKind{
+		"unspecified": ptrace.SpanKindUnspecified,
+		"internal":    ptrace.SpanKindInternal,
+		"server":      ptrace.SpanKindServer,
+		"client":      ptrace.SpanKindClient,
+		"producer":    ptrace.SpanKindProducer,
+		"consumer":    ptrace.SpanKindConsumer,
+	}
+
+	kinds := make(map[ptrace.SpanKind]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "span_kind_")
+		if name == "" {
+			continue
+		}
+		kind, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown span kind %q (valid: internal, server, client, producer, consumer, unspecified)", name)
+		}
+		kinds[kind] = true
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("-span-kinds must list at least one span kind")
+	}
+	return kinds, nil
+}
+
+// parseSpanColumns parses a comma-separated list of span table column names
+func parseSpanColumns(list string) ([]string, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := spanColumnHeaders[name]; !ok {
+			return nil, fmt.Errorf("unknown span table column %q (valid: %s)", name, strings.Join(spanColumnNames, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("span table column %q listed more than once", name)
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("-columns must list at least one column")
+	}
+	return columns, nil
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
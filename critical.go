@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// criticalMarker flags error spans matching -critical-span-pattern
+const criticalMarker = "🚨"
+
+// isCriticalError reports whether span failed and its name matches
+// -critical-span-pattern
+func (c *Config) isCriticalError(span ptrace.Span) bool {
+	return c.criticalSpanPattern != nil && span.Status().Code() == ptrace.StatusCodeError &&
+		c.criticalSpanPattern.MatchString(span.Name())
+}
+
+// hasCriticalError reports whether any span in the trace is a critical error
+func (ti *traceInfo) hasCriticalError(config *Config) bool {
+	if config.criticalSpanPattern == nil {
+		return false
+	}
+	for _, si := range ti.spans {
+		if config.isCriticalError(si.span) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorMarker returns the marker for a failed span: criticalMarker for
+// critical errors, otherwise ⚠️
+func (c *Config) errorMarker(span ptrace.Span) string {
+	if c.isCriticalError(span) {
+		return criticalMarker
+	}
+	return "⚠️"
+}
+
+// prioritizeCriticalTraces moves traces with critical errors to the front,
+// keeping the order within each group
+func prioritizeCriticalTraces(traces []*traceInfo, config *Config) {
+	if config.criticalSpanPattern == nil {
+		return
+	}
+	critical := make(map[*traceInfo]bool, len(traces))
+	for _, ti := range traces {
+		critical[ti] = ti.hasCriticalError(config)
+	}
+	sort.SliceStable(traces, func(i, j int) bool {
+		return critical[traces[i]] && !critical[traces[j]]
+	})
+}
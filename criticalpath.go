@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// criticalSegment is a stretch of time on the critical path attributed to
+// the span that was blocking progress during it
+type criticalSegment struct {
+	node       *spanTreeNode
+	start, end pcommon.Timestamp
+}
+
+// criticalPathSummary compares a trace's critical path against the total
+// work done by its spans
+type criticalPathSummary struct {
+	length      time.Duration
+	spanNumbers []int   // spans on the path in start order
+	parallelism float64 // total span self time divided by the path length
+}
+
+// computeCriticalPath walks the span tree backwards from the root's end,
+// at each point following the child that finished last, to find the chain
+// of spans that determined the trace's latency
+func computeCriticalPath(ti *traceInfo) (criticalPathSummary, bool) {
+	if len(ti.spans) == 0 {
+		return criticalPathSummary{}, false
+	}
+	root := buildSpanTree(ti)
+
+	var segments []criticalSegment
+	walkCriticalPath(root, root.spanInfo.span.EndTimestamp(), &segments)
+	if len(segments) == 0 {
+		return criticalPathSummary{}, false
+	}
+
+	var summary criticalPathSummary
+	var nodes []*spanTreeNode
+	seen := make(map[*spanTreeNode]bool)
+	for _, seg := range segments {
+		summary.length += time.Duration(seg.end - seg.start)
+		if !seen[seg.node] {
+			seen[seg.node] = true
+			nodes = append(nodes, seg.node)
+		}
+	}
+
+	// List the path from the outermost span inwards, in start order
+	sort.Slice(nodes, func(i, j int) bool {
+		si, sj := nodes[i].spanInfo.span.StartTimestamp(), nodes[j].spanInfo.span.StartTimestamp()
+		if si != sj {
+			return si < sj
+		}
+		if nodes[i].depth != nodes[j].depth {
+			return nodes[i].depth < nodes[j].depth
+		}
+		return nodes[i].spanIndex < nodes[j].spanIndex
+	})
+	for _, node := range nodes {
+		summary.spanNumbers = append(summary.spanNumbers, node.spanIndex)
+	}
+	if summary.length > 0 {
+		summary.parallelism = float64(totalSelfTime(root)) / float64(summary.length)
+	}
+	return summary, true
+}
+
+// walkCriticalPath appends the critical path segments of node that end at or
+// before cursor, newest first
+func walkCriticalPath(node *spanTreeNode, cursor pcommon.Timestamp, segments *[]criticalSegment) {
+	span := node.spanInfo.span
+	start := span.StartTimestamp()
+	if span.EndTimestamp() < cursor {
+		cursor = span.EndTimestamp()
+	}
+
+	visited := make(map[*spanTreeNode]bool)
+	for cursor > start {
+		// Follow the child that was still running closest to the cursor
+		var next *spanTreeNode
+		var nextEnd pcommon.Timestamp
+		for _, child := range node.children {
+			childSpan := child.spanInfo.span
+			if visited[child] || childSpan.StartTimestamp() >= cursor {
+				continue
+			}
+			end := min(childSpan.EndTimestamp(), cursor)
+			if next == nil || end > nextEnd {
+				next, nextEnd = child, end
+			}
+		}
+		if next == nil {
+			break
+		}
+		visited[next] = true
+
+		// Time after the child finished was spent in this span itself
+		if nextEnd < cursor {
+			*segments = append(*segments, criticalSegment{node: node, start: nextEnd, end: cursor})
+		}
+		walkCriticalPath(next, nextEnd, segments)
+		cursor = max(next.spanInfo.span.StartTimestamp(), start)
+	}
+
+	if start < cursor {
+		*segments = append(*segments, criticalSegment{node: node, start: start, end: cursor})
+	}
+}
+
+// totalSelfTime sums, over every span in the tree, the time it spent
+// without any child running
+func totalSelfTime(node *spanTreeNode) time.Duration {
+	children := make([]ptrace.Span, len(node.children))
+	var total time.Duration
+	for i, child := range node.children {
+		total += totalSelfTime(child)
+		children[i] = child.spanInfo.span
+	}
+	return total + selfTime(node.spanInfo.span, children)
+}
+
+// selfTime returns how long span ran without any of children running, i.e.
+// its duration minus the union of the children's intervals within it
+func selfTime(span ptrace.Span, children []ptrace.Span) time.Duration {
+	start, end := span.StartTimestamp(), span.EndTimestamp()
+	if end < start {
+		return 0
+	}
+
+	type interval struct{ start, end pcommon.Timestamp }
+	var busy []interval
+	for _, child := range children {
+		childStart := max(child.StartTimestamp(), start)
+		childEnd := min(child.EndTimestamp(), end)
+		if childStart < childEnd {
+			busy = append(busy, interval{childStart, childEnd})
+		}
+	}
+
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start < busy[j].start })
+	var covered time.Duration
+	var current interval
+	for i, iv := range busy {
+		if i == 0 || iv.start > current.end {
+			if i > 0 {
+				covered += time.Duration(current.end - current.start)
+			}
+			current = iv
+		} else if iv.end > current.end {
+			current.end = iv.end
+		}
+	}
+	if len(busy) > 0 {
+		covered += time.Duration(current.end - current.start)
+	}
+	return max(time.Duration(end-start)-covered, 0)
+}
+
+// spanSelfTimes returns the self time of every span in the trace, indexed
+// like ti.spans
+func spanSelfTimes(ti *traceInfo) []time.Duration {
+	children := make(map[pcommon.SpanID][]ptrace.Span)
+	for _, si := range ti.spans {
+		if parent := si.span.ParentSpanID(); !parent.IsEmpty() {
+			children[parent] = append(children[parent], si.span)
+		}
+	}
+	times := make([]time.Duration, len(ti.spans))
+	for i, si := range ti.spans {
+		var kids []ptrace.Span
+		if !si.span.SpanID().IsEmpty() {
+			kids = children[si.span.SpanID()]
+		}
+		times[i] = selfTime(si.span, kids)
+	}
+	return times
+}
+
+// formatSpanChain renders span numbers as "#1 → #3 → #4"
+func formatSpanChain(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = fmt.Sprintf("#%d", n)
+	}
+	return strings.Join(parts, " → ")
+}
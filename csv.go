@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// csvHeader lists the columns of the per-span CSV report
+var csvHeader = []string{"trace_id", "span_id", "parent_id", "service", "name", "kind", "start_unix_ns", "duration_ns", "status", "error"}
+
+// WriteCSV writes one row per span for spreadsheet analysis
+func (s *storageSnapshot) WriteCSV(w io.Writer, config *Config) error {
+	traces, _ := s.reportTraces(config)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, ti := range traces {
+		for _, si := range ti.spans {
+			span := si.span
+			service, _ := spanServiceName(si.resource, span, config)
+			parentID := ""
+			if !span.ParentSpanID().IsEmpty() {
+				parentID = span.ParentSpanID().String()
+			}
+			err := cw.Write([]string{
+				ti.partID(),
+				span.SpanID().String(),
+				parentID,
+				service,
+				span.Name(),
+				span.Kind().String(),
+				strconv.FormatUint(uint64(span.StartTimestamp()), 10),
+				strconv.FormatInt(int64(span.EndTimestamp()-span.StartTimestamp()), 10),
+				span.Status().Code().String(),
+				strconv.FormatBool(span.Status().Code() == ptrace.StatusCodeError),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// downstreamAddressKeys are the CLIENT span attributes naming the remote
+// service, current semantic conventions first
+var downstreamAddressKeys = []string{"server.address", "net.peer.name"}
+
+// uninstrumentedMarker flags CLIENT spans whose downstream isn't traced
+const uninstrumentedMarker = "📡 uninstrumented downstream"
+
+// uninstrumentedDownstreams finds CLIENT spans that name a remote address but
+// have no SERVER child from another service, i.e. calls into a downstream
+// that isn't instrumented. It maps span numbers (1-based, as in the report)
+// to the address they called.
+func uninstrumentedDownstreams(ti *traceInfo, config *Config) map[int]string {
+	children := make(map[pcommon.SpanID][]spanInfo)
+	for _, si := range ti.spans {
+		if parent := si.span.ParentSpanID(); !parent.IsEmpty() {
+			children[parent] = append(children[parent], si)
+		}
+	}
+
+	targets := make(map[int]string)
+	for i, si := range ti.spans {
+		if si.span.Kind() != ptrace.SpanKindClient {
+			continue
+		}
+		target := downstreamAddress(si.span)
+		if target == "" {
+			continue
+		}
+		if si.span.SpanID().IsEmpty() || !hasRemoteServerChild(si, children[si.span.SpanID()], config) {
+			targets[i+1] = target
+		}
+	}
+	return targets
+}
+
+// downstreamAddress returns the remote address a CLIENT span called, or ""
+func downstreamAddress(span ptrace.Span) string {
+	for _, key := range downstreamAddressKeys {
+		if value, ok := span.Attributes().Get(key); ok && value.AsString() != "" {
+			return value.AsString()
+		}
+	}
+	return ""
+}
+
+// hasRemoteServerChild reports whether any of children is a SERVER span from
+// a different service than client
+func hasRemoteServerChild(client spanInfo, children []spanInfo, config *Config) bool {
+	service, _ := spanServiceName(client.resource, client.span, config)
+	for _, child := range children {
+		if child.span.Kind() != ptrace.SpanKindServer {
+			continue
+		}
+		if childService, _ := spanServiceName(child.resource, child.span, config); childService != service {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import "strings"
+
+// markdownEscaper backslash-escapes the characters that start emphasis, code
+// spans and links in span data, and turns the ones that would start inline
+// HTML (which GitHub renders) into entities, so a name like "<unknown>" or
+// "a*b" shows up as written
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// escapeMarkdown makes span data such as span, service and scope names or
+// status messages safe to embed as plain text in a markdown report. Pipes
+// and newlines are left to escapeTableCell.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// tableCellEscaper keeps span data from breaking a markdown table: a pipe
+// would end the cell and a newline the row. GitHub also honors the escaped
+// pipe inside code spans.
+var tableCellEscaper = strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ", "\r", " ")
+
+// escapeTableCell makes text safe to put in a markdown table cell
+func escapeTableCell(text string) string {
+	return tableCellEscaper.Replace(text)
+}
+
+// codeSpan wraps text in a markdown code span, using a longer backtick fence
+// when the text itself contains backticks
+func codeSpan(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	if longest == 0 {
+		return "`" + text + "`"
+	}
+	fence := strings.Repeat("`", longest+1)
+	return fence + " " + text + " " + fence
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// otlpExportFormat writes the -export-otlp file
+var otlpExportFormat = outputFormat{name: "OTLP protobuf", write: (*storageSnapshot).WriteOTLP}
+
+// WriteOTLP writes every retained batch, after span filtering and sampling,
+// as a single OTLP protobuf ExportTraceServiceRequest that can be replayed
+// into another backend or read back with -input
+func (s *storageSnapshot) WriteOTLP(w io.Writer, config *Config) error {
+	traces := ptrace.NewTraces()
+	for _, entry := range s.traces {
+		for i := 0; i < entry.traces.ResourceSpans().Len(); i++ {
+			entry.traces.ResourceSpans().At(i).CopyTo(traces.ResourceSpans().AppendEmpty())
+		}
+	}
+
+	data, err := ptraceotlp.NewExportRequestFromTraces(traces).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal traces: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// outputPaths returns every file written at shutdown: the -output reports
+// followed by the -export-otlp file
+func (c *Config) outputPaths() []string {
+	if c.ExportOTLP == "" {
+		return c.OutputFiles
+	}
+	return append(append([]string(nil), c.OutputFiles...), c.ExportOTLP)
+}
+
+// formatFor returns the writer for one of outputPaths
+func (c *Config) formatFor(path string) (outputFormat, error) {
+	if c.ExportOTLP != "" && path == c.ExportOTLP {
+		return otlpExportFormat, nil
+	}
+	return outputFormatFor(path)
+}
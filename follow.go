@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// followMaxErrors is how many new error traces each -follow update lists
+const followMaxErrors = 5
+
+// traceFollower remembers which traces earlier -follow updates covered, so
+// each update only reports what arrived since
+type traceFollower struct {
+	seen    map[string]bool
+	errored map[string]bool
+}
+
+// followTraces logs the traces and errors received since the previous update
+// every interval until stop is closed
+func followTraces(storage *TraceStorage, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	follower := &traceFollower{seen: make(map[string]bool), errored: make(map[string]bool)}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			follower.update(groupTraces(storage.Snapshot().traces))
+		}
+	}
+}
+
+// update logs the traces that are new since the last update and the traces
+// that started failing, then remembers the current set. Nothing is logged
+// when nothing changed.
+func (f *traceFollower) update(traces []*traceInfo) {
+	newTraces := 0
+	var newErrors []*traceInfo
+	seen := make(map[string]bool, len(traces))
+	errored := make(map[string]bool)
+	for _, ti := range traces {
+		seen[ti.traceID] = true
+		if !f.seen[ti.traceID] {
+			newTraces++
+		}
+		if ti.hasError() {
+			errored[ti.traceID] = true
+			if !f.errored[ti.traceID] {
+				newErrors = append(newErrors, ti)
+			}
+		}
+	}
+	// Forget traces that were evicted, expired or cleared
+	f.seen, f.errored = seen, errored
+
+	if newTraces == 0 && len(newErrors) == 0 {
+		return
+	}
+	log.Printf("Follow: %d new traces, %d newly failing (%d stored)", newTraces, len(newErrors), len(traces))
+	for i, ti := range newErrors {
+		if i == followMaxErrors {
+			log.Printf("  ... and %d more", len(newErrors)-followMaxErrors)
+			break
+		}
+		message := ti.firstErrorMessage()
+		if message == "" {
+			message = "no status message"
+		}
+		log.Printf("  🔴 %s (%s): %s", ti.getRootSpanName(), ti.traceID, truncateText(message, maxStatusSnippet))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// forwardQueueSize is how many batches can wait for the downstream
+	// endpoint before new batches are dropped
+	forwardQueueSize = 1000
+
+	// forwardTimeout bounds a single export to the downstream endpoint
+	forwardTimeout = 10 * time.Second
+)
+
+// traceForwarder re-exports received batches to a downstream OTLP gRPC
+// endpoint so tracedown can sit inline in front of a real backend. Exports
+// run on a background goroutine so a slow backend never blocks ingest.
+type traceForwarder struct {
+	addr   string
+	conn   *grpc.ClientConn
+	client ptraceotlp.GRPCClient
+	queue  chan ptrace.Traces
+	done   chan struct{}
+
+	mu        sync.Mutex
+	forwarded int
+	failed    int
+	dropped   int
+}
+
+// newTraceForwarder connects to the downstream endpoint and starts the export loop
+func newTraceForwarder(addr string) (*traceForwarder, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", addr, err)
+	}
+
+	f := &traceForwarder{
+		addr:   addr,
+		conn:   conn,
+		client: ptraceotlp.NewGRPCClient(conn),
+		queue:  make(chan ptrace.Traces, forwardQueueSize),
+		done:   make(chan struct{}),
+	}
+	go f.run()
+	return f, nil
+}
+
+// Forward queues a copy of traces for export, dropping it if the queue is full.
+// A nil forwarder does nothing.
+func (f *traceForwarder) Forward(traces ptrace.Traces) {
+	if f == nil {
+		return
+	}
+
+	cloned := ptrace.NewTraces()
+	traces.CopyTo(cloned)
+
+	select {
+	case f.queue <- cloned:
+	default:
+		f.mu.Lock()
+		f.dropped++
+		f.mu.Unlock()
+		log.Printf("Warning: Forward queue to %s is full, dropping batch", f.addr)
+	}
+}
+
+func (f *traceForwarder) run() {
+	defer close(f.done)
+
+	for traces := range f.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+		_, err := f.client.Export(ctx, ptraceotlp.NewExportRequestFromTraces(traces))
+		cancel()
+
+		f.mu.Lock()
+		if err != nil {
+			f.failed++
+		} else {
+			f.forwarded++
+		}
+		f.mu.Unlock()
+
+		if err != nil {
+			log.Printf("Warning: Failed to forward batch to %s: %v", f.addr, err)
+		}
+	}
+}
+
+// Close stops accepting batches and waits up to timeout for queued batches
+// to be exported before closing the connection
+func (f *traceForwarder) Close(timeout time.Duration) {
+	if f == nil {
+		return
+	}
+
+	close(f.queue)
+	select {
+	case <-f.done:
+	case <-time.After(timeout):
+		log.Printf("Warning: Timed out forwarding %d queued batches to %s", len(f.queue), f.addr)
+	}
+	f.conn.Close()
+}
+
+// Stats returns how many batches were forwarded, failed and dropped
+func (f *traceForwarder) Stats() (forwarded, failed, dropped int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.forwarded, f.failed, f.dropped
+}
@@ -3,6 +3,7 @@ module github.com/spacelift-solutions/tracedown
 go 1.25.1
 
 require (
+	github.com/soheilhy/cmux v0.1.5
 	go.opentelemetry.io/collector/pdata v1.45.0
 	google.golang.org/grpc v1.76.0
 )
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseGroupBy parses a -group-by value of the form attr:<key> into the
+// attribute key
+func parseGroupBy(spec string) (string, error) {
+	kind, key, ok := strings.Cut(spec, ":")
+	key = strings.TrimSpace(key)
+	if !ok || kind != "attr" || key == "" {
+		return "", fmt.Errorf("invalid group-by %q (expected attr:<key>, e.g. attr:session.id)", spec)
+	}
+	return key, nil
+}
+
+// traceGroup is a set of traces sharing a -group-by attribute value
+type traceGroup struct {
+	value  string // "" for traces without the attribute
+	traces []*traceInfo
+}
+
+// groupValue returns the value of key for a trace: from the root span,
+// then any other span, then the root's resource; "" when none has it
+func (ti *traceInfo) groupValue(key string) string {
+	root, ok := ti.findRootSpan()
+	if !ok {
+		return ""
+	}
+	if val, ok := root.span.Attributes().Get(key); ok && val.AsString() != "" {
+		return val.AsString()
+	}
+	for _, si := range ti.spans {
+		if val, ok := si.span.Attributes().Get(key); ok && val.AsString() != "" {
+			return val.AsString()
+		}
+	}
+	if val, ok := root.resource.Attributes().Get(key); ok {
+		return val.AsString()
+	}
+	return ""
+}
+
+// groupTracesBy splits traces by their value of key, in order of each
+// group's first trace, with traces lacking the key in a last ungrouped group
+func groupTracesBy(traces []*traceInfo, key string) []traceGroup {
+	var groups []traceGroup
+	index := make(map[string]int)
+	var ungrouped []*traceInfo
+	for _, ti := range traces {
+		value := ti.groupValue(key)
+		if value == "" {
+			ungrouped = append(ungrouped, ti)
+			continue
+		}
+		i, ok := index[value]
+		if !ok {
+			i = len(groups)
+			index[value] = i
+			groups = append(groups, traceGroup{value: value})
+		}
+		groups[i].traces = append(groups[i].traces, ti)
+	}
+	if len(ungrouped) > 0 {
+		groups = append(groups, traceGroup{traces: ungrouped})
+	}
+	return groups
+}
+
+// heading names a group, e.g. "`session.id` = abc (3 trace(s))"
+func (g traceGroup) heading(key string) string {
+	if g.value == "" {
+		return fmt.Sprintf("Ungrouped (%d trace(s) without %s)", len(g.traces), codeSpan(key))
+	}
+	return fmt.Sprintf("%s = %s (%d trace(s))", codeSpan(key), escapeMarkdown(g.value), len(g.traces))
+}
+
+// orderByGroup returns the traces of groups in group order, so trace numbers
+// run consecutively within each group
+func orderByGroup(groups []traceGroup) []*traceInfo {
+	var ordered []*traceInfo
+	for _, g := range groups {
+		ordered = append(ordered, g.traces...)
+	}
+	return ordered
+}
+
+// writeGroupedTraces writes the table of contents and trace sections with
+// traces combined under their -group-by value instead of by status. traces
+// must already be in orderByGroup order.
+func writeGroupedTraces(f io.Writer, traces []*traceInfo, groups []traceGroup, config *Config) error {
+	fmt.Fprintf(f, "## Table of Contents\n\n")
+	firstOfGroup := make(map[*traceInfo]string)
+	for _, g := range groups {
+		fmt.Fprintf(f, "### %s\n", g.heading(config.groupByKey))
+		writeTOCTable(f, traces, g.traces, config)
+		fmt.Fprintf(f, "\n")
+		firstOfGroup[g.traces[0]] = g.heading(config.groupByKey)
+	}
+	fmt.Fprintf(f, "---\n\n")
+
+	return renderTraces(f, traces, config, func(w io.Writer, index int, ti *traceInfo) {
+		if heading, ok := firstOfGroup[ti]; ok {
+			fmt.Fprintf(w, "## 🔗 %s\n\n", heading)
+		}
+		writeTrace(w, index, ti, config)
+	}, func(w io.Writer, index int, ti *traceInfo, reason string) {
+		if heading, ok := firstOfGroup[ti]; ok {
+			fmt.Fprintf(w, "## 🔗 %s\n\n", heading)
+		}
+		writeTraceFailure(w, index, ti, reason, config)
+	})
+}
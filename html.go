@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const htmlStyle = `body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; vertical-align: top; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+.error { color: #cf222e; }`
+
+// writeHTMLInternalStats writes the overview rows about collection itself,
+// see writeInternalStats
+func (s *storageSnapshot) writeHTMLInternalStats(f io.Writer, traces []*traceInfo, filtered int, config *Config) {
+	fmt.Fprintf(f, "<tr><td>Batches Stored</td><td>%d</td></tr>\n", len(s.traces))
+	if s.lifetimeBatches != len(s.traces) {
+		fmt.Fprintf(f, "<tr><td>Batches Received (whole run)</td><td>%d</td></tr>\n", s.lifetimeBatches)
+		fmt.Fprintf(f, "<tr><td>Spans Received (whole run)</td><td>%d</td></tr>\n", s.lifetimeSpans)
+	}
+	totalDropped := s.droppedOldest + s.droppedTraces
+	if totalDropped > 0 {
+		fmt.Fprintf(f, "<tr><td>Traces Dropped</td><td>%d</td></tr>\n", totalDropped)
+	}
+	if s.filteredSpans > 0 {
+		fmt.Fprintf(f, "<tr><td>Spans Dropped (match rules)</td><td>%d</td></tr>\n", s.filteredSpans)
+	}
+	if s.sampledSpans > 0 {
+		fmt.Fprintf(f, "<tr><td>Spans Dropped (per-trace limit)</td><td>%d</td></tr>\n", s.sampledSpans)
+	}
+	if filtered > 0 {
+		fmt.Fprintf(f, "<tr><td>Traces Filtered (&lt; %d spans)</td><td>%d</td></tr>\n", config.MinSpans, filtered)
+	}
+	if incomplete := countIncompleteTraces(traces, config); incomplete > 0 {
+		fmt.Fprintf(f, "<tr><td>Traces Missing Required Spans</td><td>%d</td></tr>\n", incomplete)
+	}
+	if skewed := countSkewedTraces(traces); skewed > 0 {
+		fmt.Fprintf(f, "<tr><td>Traces with Spans Before Root</td><td>%d</td></tr>\n", skewed)
+	}
+}
+
+// WriteHTML renders the stored traces as a standalone HTML page
+func (s *storageSnapshot) WriteHTML(f io.Writer, config *Config) error {
+	fmt.Fprintf(f, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(f, "<title>%s</title>\n", html.EscapeString(config.ReportTitle))
+	fmt.Fprintf(f, "<style>\n%s\n</style>\n</head>\n<body>\n", htmlStyle)
+	fmt.Fprintf(f, "<h1>%s</h1>\n", html.EscapeString(config.ReportTitle))
+
+	// Write overview table
+	fmt.Fprintf(f, "<h2>Overview</h2>\n<table>\n")
+	fmt.Fprintf(f, "<tr><th>Metric</th><th>Value</th></tr>\n")
+	fmt.Fprintf(f, "<tr><td>Generated</td><td>%s</td></tr>\n", time.Now().Format(time.RFC3339))
+	traces, filtered := s.reportTraces(config)
+	fmt.Fprintf(f, "<tr><td>Total Traces</td><td>%d</td></tr>\n", len(traces))
+	if rate, ok := errorRate(traces); ok {
+		fmt.Fprintf(f, "<tr><td>Error Rate</td><td>%s</td></tr>\n", rate)
+	}
+	if !config.HideInternalStats {
+		s.writeHTMLInternalStats(f, traces, filtered, config)
+	}
+	fmt.Fprintf(f, "</table>\n")
+	if config.ReportNote != "" {
+		fmt.Fprintf(f, "<p>%s</p>\n", html.EscapeString(config.ReportNote))
+	}
+
+	if len(s.traces) == 0 {
+		fmt.Fprintf(f, "<p>No traces were collected.</p>\n</body>\n</html>\n")
+		return nil
+	}
+	if len(traces) == 0 {
+		fmt.Fprintf(f, "<p>No traces matched the report filters.</p>\n</body>\n</html>\n")
+		return nil
+	}
+
+	if large := findLargeTraces(traces, config.SpanCountWarn); len(large) > 0 {
+		fmt.Fprintf(f, "<h2>⚠️ Unusually Large Traces</h2>\n<p>%d trace(s) have more than %d spans, which often indicates an instrumentation bug such as spans created in a loop.</p>\n<table>\n",
+			len(large), config.SpanCountWarn)
+		fmt.Fprintf(f, "<tr><th>Trace</th><th>Trace ID</th><th>Spans</th><th>Root Operation</th></tr>\n")
+		numbers := traceNumbers(traces)
+		for _, ti := range large {
+			traceNum := numbers[ti]
+			fmt.Fprintf(f, "<tr><td><a href=\"#trace-%d\">#%d</a></td><td><code>%s</code></td><td>%d</td><td>%s</td></tr>\n",
+				traceNum, traceNum, ti.partID(), len(ti.spans), html.EscapeString(ti.getRootSpanName()))
+		}
+		fmt.Fprintf(f, "</table>\n")
+	}
+
+	// Write Table of Contents
+	showEnv := anyEnvironment(traces)
+	fmt.Fprintf(f, "<h2>Table of Contents</h2>\n<table>\n")
+	fmt.Fprintf(f, "<tr><th>Trace</th>")
+	if showEnv {
+		fmt.Fprintf(f, "<th>Env</th>")
+	}
+	fmt.Fprintf(f, "<th>Service</th><th>Duration</th><th>Spans</th><th>Root Operation</th><th>Status</th><th>Received</th></tr>\n")
+	for idx, ti := range traces {
+		fmt.Fprintf(f, "<tr><td><a href=\"#trace-%d\">#%d</a></td>", idx+1, idx+1)
+		if showEnv {
+			fmt.Fprintf(f, "<td>%s</td>", html.EscapeString(envBadge(ti.getEnvironment())))
+		}
+		fmt.Fprintf(f, "<td>%s</td><td>%v</td><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(ti.getServiceName(config)), ti.getDuration(), len(ti.spans),
+			html.EscapeString(ti.getTraceLabel(config.traceLabelAttrs)), htmlTOCStatus(ti),
+			formatReceived(ti.firstArrival, config))
+	}
+	fmt.Fprintf(f, "</table>\n")
+
+	// Write each trace
+	err := renderTraces(f, traces, config, func(w io.Writer, index int, ti *traceInfo) {
+		writeHTMLTrace(w, index, ti, config)
+	}, writeHTMLTraceFailure)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(f, "</body>\n</html>\n")
+	return nil
+}
+
+func writeHTMLTrace(f io.Writer, index int, ti *traceInfo, config *Config) {
+	duration := ti.getDuration()
+
+	if name := ti.displayName(config); name != ti.traceID {
+		fmt.Fprintf(f, "<h2 id=\"trace-%d\">Trace %d: %s</h2>\n", index, index, html.EscapeString(name))
+		fmt.Fprintf(f, "<p><em>Trace ID: <code>%s</code></em></p>\n", ti.traceID)
+	} else {
+		fmt.Fprintf(f, "<h2 id=\"trace-%d\">Trace %d: <code>%s</code></h2>\n", index, index, ti.traceID)
+	}
+	fmt.Fprintf(f, "<p><strong>Service:</strong> %s | <strong>Duration:</strong> %v | <strong>Spans:</strong> %d | <strong>Status:</strong> %s</p>\n",
+		html.EscapeString(ti.getServiceName(config)), duration, len(ti.spans), htmlTraceStatus(ti.hasError()))
+	if ti.collisionParts > 0 {
+		fmt.Fprintf(f, "<p class=\"error\">⚠️ Trace ID reused: this is part %s of %d unrelated requests sharing the ID.</p>\n", ti.collisionPart, ti.collisionParts)
+	}
+	if len(ti.spans) > 1 {
+		if cp, ok := computeCriticalPath(ti); ok {
+			fmt.Fprintf(f, "<p><strong>Critical Path:</strong> %v of %v total (%s) | <strong>Parallelism:</strong> %.1fx</p>\n",
+				cp.length, duration, html.EscapeString(formatSpanChain(cp.spanNumbers)), cp.parallelism)
+		}
+	}
+
+	if skew, ok := ti.spansBeforeRoot(); ok {
+		fmt.Fprintf(f, "<p class=\"error\">⚠️ %s</p>\n", html.EscapeString(skew.note()))
+	}
+	if missing := ti.missingRequiredSpans(config); len(missing) > 0 {
+		fmt.Fprintf(f, "<p class=\"error\">❌ Missing required spans: %s</p>\n", html.EscapeString(strings.Join(missing, ", ")))
+	}
+
+	if !config.NoTimeline {
+		// Reuse the markdown ASCII timeline inside a preformatted block
+		var timeline strings.Builder
+		tree := buildSpanTree(ti)
+		hideSpanKinds(tree, config)
+		layout := traceTimelineLayout(ti, config)
+		writeTimeline(&timeline, tree, duration, layout)
+		fmt.Fprintf(f, "<h3>Span Timeline</h3>\n<pre>%s</pre>\n", html.EscapeString(timeline.String()))
+		if len(layout.eventMarkers) > 0 {
+			fmt.Fprintf(f, "<p><em>Events: %s</em></p>\n", html.EscapeString(eventLegend(layout.eventMarkers)))
+		}
+		if hasAsyncSpans(tree) {
+			fmt.Fprintf(f, "<p><em>%s</em></p>\n", html.EscapeString(asyncLegend))
+		}
+		if len(tree.detachedCycle) > 0 {
+			fmt.Fprintf(f, "<p class=\"error\">%s</p>\n", html.EscapeString(detachedCycleNote(tree.detachedCycle)))
+		}
+	}
+
+	if config.NoTables {
+		return
+	}
+
+	writeHTMLNumericAttrStats(f, ti, config)
+	fmt.Fprintf(f, "<h3>Span Summary</h3>\n<table>\n<tr>")
+	for _, column := range config.spanColumns {
+		fmt.Fprintf(f, "<th>%s</th>", html.EscapeString(spanColumnHeaders[column]))
+	}
+	fmt.Fprintf(f, "</tr>\n")
+	for _, row := range spanTableRows(ti, config) {
+		span := row.si.span
+		fmt.Fprintf(f, "<tr>")
+		for _, column := range config.spanColumns {
+			var cell string
+			switch column {
+			case "status":
+				cell = html.EscapeString(row.text(column))
+				if span.Status().Code() == ptrace.StatusCodeError {
+					cell = fmt.Sprintf("<span class=\"error\">%s</span>", cell)
+				}
+			case "details":
+				var attrs []string
+				if badges := detailBadges(span); badges != "" {
+					attrs = append(attrs, "<strong>"+badges+"</strong>")
+				}
+				if dropped := sdkDroppedNote(span); dropped != "" {
+					attrs = append(attrs, html.EscapeString(dropped))
+				}
+				if semconv := semconvNote(span, config); semconv != "" {
+					attrs = append(attrs, html.EscapeString(semconv))
+				}
+				if config.SpanTimestamps {
+					attrs = append(attrs, fmt.Sprintf("start <code>%s</code>", formatSpanTimestamp(span.StartTimestamp(), config)),
+						fmt.Sprintf("end <code>%s</code>", formatSpanTimestamp(span.EndTimestamp(), config)))
+				}
+				for _, attr := range spanAttributes(span.Attributes(), config.FlattenAttrs) {
+					attrs = append(attrs, fmt.Sprintf("<code>%s</code>: %s", html.EscapeString(attr.key), html.EscapeString(attr.value.AsString())))
+				}
+				events, hidden := limitEvents(span.Events(), config.MaxEventsPerSpan)
+				for _, event := range events {
+					offset := time.Duration(max(event.Timestamp(), span.StartTimestamp()) - span.StartTimestamp())
+					attrs = append(attrs, fmt.Sprintf("event <code>%s</code> at +%v", html.EscapeString(event.Name()), offset))
+				}
+				if hidden > 0 {
+					attrs = append(attrs, fmt.Sprintf("<em>… %d more events</em>", hidden))
+				}
+				for _, member := range parseTraceState(span.TraceState().AsRaw()) {
+					attrs = append(attrs, fmt.Sprintf("tracestate <code>%s</code>: <code>%s</code>", html.EscapeString(member.key), html.EscapeString(member.value)))
+				}
+				cell = strings.Join(attrs, "<br>")
+			default:
+				cell = html.EscapeString(row.text(column))
+			}
+			fmt.Fprintf(f, "<td>%s</td>", cell)
+		}
+		fmt.Fprintf(f, "</tr>\n")
+	}
+	fmt.Fprintf(f, "</table>\n")
+}
+
+// writeHTMLTraceFailure writes the placeholder for a trace that failed to render
+func writeHTMLTraceFailure(f io.Writer, index int, ti *traceInfo, reason string) {
+	fmt.Fprintf(f, "<h2 id=\"trace-%d\">Trace %d: <code>%s</code></h2>\n", index, index, ti.partID())
+	fmt.Fprintf(f, "<p class=\"error\">⚠️ This trace could not be rendered: %s</p>\n", html.EscapeString(reason))
+}
+
+// htmlTOCStatus adds a snippet of the first error message to the trace status
+func htmlTOCStatus(ti *traceInfo) string {
+	status := htmlTraceStatus(ti.hasError())
+	if msg := ti.firstErrorMessage(); ti.hasError() && msg != "" {
+		status += ": " + html.EscapeString(truncateText(msg, maxStatusSnippet))
+	}
+	return status
+}
+
+func htmlTraceStatus(hasError bool) string {
+	if hasError {
+		return "<span class=\"error\">⚠️ ERROR</span>"
+	}
+	return "✓ OK"
+}
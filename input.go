@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// expandInputPatterns resolves -input paths and glob patterns into files,
+// keeping the order given and skipping files matched more than once
+func expandInputPatterns(patterns []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("input %q matches no files", pattern)
+		}
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// readTraceFile reads an OTLP trace dump: JSON for .json files, protobuf
+// (e.g. a -dump-on-panic file) otherwise
+func readTraceFile(path string) (ptrace.Traces, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ptrace.Traces{}, err
+	}
+
+	req := ptraceotlp.NewExportRequest()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = req.UnmarshalJSON(data)
+	} else {
+		err = req.UnmarshalProto(data)
+	}
+	if err != nil {
+		return ptrace.Traces{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return req.Traces(), nil
+}
+
+// readTraceStream parses OTLP records piped to -stdin. Protobuf input is a
+// single export request (concatenated requests merge into one); JSON input
+// may hold several requests one after another, e.g. one per line.
+func readTraceStream(r io.Reader, format string) ([]ptrace.Traces, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "proto" {
+		req := ptraceotlp.NewExportRequest()
+		if err := req.UnmarshalProto(data); err != nil {
+			return nil, fmt.Errorf("failed to parse protobuf: %w", err)
+		}
+		return []ptrace.Traces{req.Traces()}, nil
+	}
+
+	var batches []ptrace.Traces
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var record json.RawMessage
+		if err := dec.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON record %d: %w", len(batches)+1, err)
+		}
+		req := ptraceotlp.NewExportRequest()
+		if err := req.UnmarshalJSON(record); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON record %d: %w", len(batches)+1, err)
+		}
+		batches = append(batches, req.Traces())
+	}
+	return batches, nil
+}
+
+// loadStdin feeds the OTLP records read from stdin through AddTraces
+func loadStdin(storage *TraceStorage, format string) error {
+	batches, err := readTraceStream(os.Stdin, format)
+	if err != nil {
+		return fmt.Errorf("stdin: %w", err)
+	}
+	spans := 0
+	for _, traces := range batches {
+		spans += traces.SpanCount()
+		storage.AddTraces(traces)
+	}
+	log.Printf("Loaded %d spans from stdin", spans)
+	return nil
+}
+
+// loadInputFiles feeds every -input file through AddTraces, so dumps from
+// several services or hosts end up in one report
+func loadInputFiles(storage *TraceStorage, patterns []string) error {
+	files, err := expandInputPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		traces, err := readTraceFile(path)
+		if err != nil {
+			return err
+		}
+		log.Printf("Loaded %d spans from %s", traces.SpanCount(), path)
+		storage.AddTraces(traces)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// jsonReport is the machine-readable form of the trace report
+type jsonReport struct {
+	Title                 string      `json:"title"`
+	Note                  string      `json:"note,omitempty"`
+	Generated             time.Time   `json:"generated"`
+	Batches               int         `json:"batches"`
+	TotalTraces           int         `json:"total_traces"`
+	ErrorTraces           int         `json:"error_traces"`
+	TracesDropped         int         `json:"traces_dropped"`
+	TracesFiltered        int         `json:"traces_filtered"`
+	SpansDropped          int         `json:"spans_dropped"`
+	SpansFiltered         int         `json:"spans_filtered"`
+	LifetimeBatches       int         `json:"lifetime_batches"`
+	LifetimeSpans         int         `json:"lifetime_spans"`
+	SpansMalformedTraceID int         `json:"spans_malformed_trace_id"`
+	Traces                []jsonTrace `json:"traces"`
+}
+
+type jsonTrace struct {
+	TraceID           string            `json:"trace_id"`
+	Service           string            `json:"service"`
+	RootSpan          string            `json:"root_span"`
+	DurationNs        int64             `json:"duration_ns"`
+	SpanCount         int               `json:"span_count"`
+	HasError          bool              `json:"has_error"`
+	CriticalPathNs    int64             `json:"critical_path_ns"`
+	Parallelism       float64           `json:"parallelism"`
+	ReceivedAt        time.Time         `json:"received_at"`
+	Batches           int               `json:"batches"`
+	CollisionPart     string            `json:"collision_part,omitempty"`
+	NumericAttributes []jsonNumericAttr `json:"numeric_attributes,omitempty"`
+	Spans             []jsonSpan        `json:"spans"`
+}
+
+type jsonSpan struct {
+	SpanID            string         `json:"span_id"`
+	ParentSpanID      string         `json:"parent_span_id,omitempty"`
+	Name              string         `json:"name"`
+	Kind              string         `json:"kind"`
+	Service           string         `json:"service"`
+	ResourceSchemaURL string         `json:"resource_schema_url,omitempty"`
+	StartTimeUnixNano uint64         `json:"start_time_unix_nano"`
+	EndTimeUnixNano   uint64         `json:"end_time_unix_nano"`
+	DurationNs        int64          `json:"duration_ns"`
+	Status            string         `json:"status"`
+	StatusMessage     string         `json:"status_message,omitempty"`
+	TraceState        string         `json:"trace_state,omitempty"`
+	DroppedAttributes uint32         `json:"dropped_attributes_count,omitempty"`
+	DroppedEvents     uint32         `json:"dropped_events_count,omitempty"`
+	DroppedLinks      uint32         `json:"dropped_links_count,omitempty"`
+	Attributes        map[string]any `json:"attributes,omitempty"`
+	Scope             *jsonScope     `json:"scope,omitempty"`
+	Events            []jsonEvent    `json:"events,omitempty"`
+	Links             []jsonLink     `json:"links,omitempty"`
+}
+
+// jsonNumericAttr is a -numeric-attr-stats aggregate for one attribute key
+type jsonNumericAttr struct {
+	Key   string  `json:"key"`
+	Spans int     `json:"spans"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+}
+
+type jsonScope struct {
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	SchemaURL  string         `json:"schema_url,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+type jsonEvent struct {
+	Name         string         `json:"name"`
+	TimeUnixNano uint64         `json:"time_unix_nano"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+}
+
+type jsonLink struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+// WriteJSON renders the stored traces as a JSON document
+func (s *storageSnapshot) WriteJSON(w io.Writer, config *Config) error {
+	traces, filtered := s.reportTraces(config)
+
+	report := jsonReport{
+		Title:                 config.ReportTitle,
+		Note:                  config.ReportNote,
+		Generated:             time.Now(),
+		Batches:               len(s.traces),
+		TotalTraces:           len(traces),
+		ErrorTraces:           countErrorTraces(traces),
+		TracesDropped:         s.droppedOldest + s.droppedTraces,
+		TracesFiltered:        filtered,
+		SpansDropped:          s.sampledSpans,
+		SpansFiltered:         s.filteredSpans,
+		LifetimeBatches:       s.lifetimeBatches,
+		LifetimeSpans:         s.lifetimeSpans,
+		SpansMalformedTraceID: s.malformedTraceIDSpans,
+		Traces:                make([]jsonTrace, 0, len(traces)),
+	}
+	for _, ti := range traces {
+		report.Traces = append(report.Traces, buildJSONTrace(ti, config))
+	}
+
+	enc := json.NewEncoder(w)
+	if !config.CompactJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(report)
+}
+
+func buildJSONTrace(ti *traceInfo, config *Config) jsonTrace {
+	jt := jsonTrace{
+		TraceID:       ti.traceID,
+		Service:       ti.getServiceName(config),
+		RootSpan:      ti.getRootSpanName(),
+		DurationNs:    ti.getDuration().Nanoseconds(),
+		SpanCount:     len(ti.spans),
+		HasError:      ti.hasError(),
+		ReceivedAt:    ti.firstArrival,
+		Batches:       ti.batches,
+		CollisionPart: ti.collisionPart,
+		Spans:         make([]jsonSpan, 0, len(ti.spans)),
+	}
+
+	if cp, ok := computeCriticalPath(ti); ok {
+		jt.CriticalPathNs = cp.length.Nanoseconds()
+		jt.Parallelism = cp.parallelism
+	}
+
+	for _, s := range traceNumericAttrStats(ti, config) {
+		jt.NumericAttributes = append(jt.NumericAttributes, jsonNumericAttr{Key: s.key, Spans: s.spans, Min: s.min, Max: s.max, Sum: s.sum})
+	}
+
+	for _, si := range ti.spans {
+		span := si.span
+		js := jsonSpan{
+			SpanID:            span.SpanID().String(),
+			Name:              span.Name(),
+			Kind:              span.Kind().String(),
+			StartTimeUnixNano: uint64(span.StartTimestamp()),
+			EndTimeUnixNano:   uint64(span.EndTimestamp()),
+			DurationNs:        time.Duration(span.EndTimestamp() - span.StartTimestamp()).Nanoseconds(),
+			Status:            span.Status().Code().String(),
+			StatusMessage:     span.Status().Message(),
+			TraceState:        span.TraceState().AsRaw(),
+			DroppedAttributes: span.DroppedAttributesCount(),
+			DroppedEvents:     span.DroppedEventsCount(),
+			DroppedLinks:      span.DroppedLinksCount(),
+			Attributes:        jsonAttributes(span.Attributes()),
+			ResourceSchemaURL: si.resourceSchemaURL,
+		}
+		if !span.ParentSpanID().IsEmpty() {
+			js.ParentSpanID = span.ParentSpanID().String()
+		}
+		js.Service, _ = spanServiceName(si.resource, span, config)
+		if si.scope.Name() != "" || si.scopeSchemaURL != "" {
+			js.Scope = &jsonScope{
+				Name:       si.scope.Name(),
+				Version:    si.scope.Version(),
+				SchemaURL:  si.scopeSchemaURL,
+				Attributes: jsonAttributes(si.scope.Attributes()),
+			}
+		}
+
+		for i := 0; i < span.Events().Len(); i++ {
+			event := span.Events().At(i)
+			js.Events = append(js.Events, jsonEvent{
+				Name:         event.Name(),
+				TimeUnixNano: uint64(event.Timestamp()),
+				Attributes:   jsonAttributes(event.Attributes()),
+			})
+		}
+
+		for i := 0; i < span.Links().Len(); i++ {
+			link := span.Links().At(i)
+			js.Links = append(js.Links, jsonLink{
+				TraceID: link.TraceID().String(),
+				SpanID:  link.SpanID().String(),
+			})
+		}
+
+		jt.Spans = append(jt.Spans, js)
+	}
+
+	return jt
+}
+
+// jsonAttributes converts an attribute map for JSON output, or nil when empty
+func jsonAttributes(attrs pcommon.Map) map[string]any {
+	if attrs.Len() == 0 {
+		return nil
+	}
+	result := make(map[string]any, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		result[k] = valueToJSON(v)
+		return true
+	})
+	return result
+}
+
+// valueToJSON maps an attribute value to the native Go type matching its
+// OTLP type, so JSON output keeps numbers, booleans and nesting intact
+// instead of the stringified form used by formatValue
+func valueToJSON(val pcommon.Value) any {
+	switch val.Type() {
+	case pcommon.ValueTypeStr:
+		return val.Str()
+	case pcommon.ValueTypeInt:
+		return val.Int()
+	case pcommon.ValueTypeDouble:
+		return doubleToJSON(val.Double())
+	case pcommon.ValueTypeBool:
+		return val.Bool()
+	case pcommon.ValueTypeBytes:
+		return base64.StdEncoding.EncodeToString(val.Bytes().AsRaw())
+	case pcommon.ValueTypeSlice:
+		slice := val.Slice()
+		items := make([]any, 0, slice.Len())
+		for i := 0; i < slice.Len(); i++ {
+			items = append(items, valueToJSON(slice.At(i)))
+		}
+		return items
+	case pcommon.ValueTypeMap:
+		m := make(map[string]any, val.Map().Len())
+		val.Map().Range(func(k string, v pcommon.Value) bool {
+			m[k] = valueToJSON(v)
+			return true
+		})
+		return m
+	default:
+		return nil
+	}
+}
+
+// doubleToJSON returns a double attribute as a number, or as the string
+// "NaN", "+Inf" or "-Inf" when it has no JSON number form
+func doubleToJSON(v float64) any {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return v
+}
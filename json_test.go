@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSONNonFiniteDoubles(t *testing.T) {
+	ti := newTestTrace(testSpan{1, 0, "root"})
+	attrs := ti.spans[0].span.Attributes()
+	attrs.PutDouble("ratio", math.NaN())
+	attrs.PutDouble("upper", math.Inf(1))
+	attrs.PutDouble("lower", math.Inf(-1))
+	attrs.PutDouble("finite", 0.5)
+
+	out, err := json.Marshal(buildJSONTrace(ti, testConfig(t)))
+	if err != nil {
+		t.Fatalf("trace with non-finite attributes doesn't encode: %v", err)
+	}
+	for _, want := range []string{`"ratio":"NaN"`, `"upper":"+Inf"`, `"lower":"-Inf"`, `"finite":0.5`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("JSON doesn't contain %s:\n%s", want, out)
+		}
+	}
+}
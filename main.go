@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,11 +10,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/soheilhy/cmux"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 // Version information set by ldflags at build time
@@ -30,30 +36,118 @@ func main() {
 	if err := config.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
+	if err := config.LoadBaseline(); err != nil {
+		log.Fatalf("Failed to load baseline: %v", err)
+	}
 
 	config.PrintConfig()
 
 	// Initialize trace storage
 	storage := NewTraceStorage(config)
 
+	// Build the report from dump files or stdin instead of listening
+	if config.OfflineInput() {
+		if err := loadInputFiles(storage, config.InputFiles); err != nil {
+			log.Fatalf("Failed to load input: %v", err)
+		}
+		if config.Stdin {
+			if err := loadStdin(storage, config.InputFormat); err != nil {
+				log.Fatalf("Failed to load input: %v", err)
+			}
+		}
+		writeReportsAndCheck(storage, config)
+		return
+	}
+
+	// Watch for traces that stopped receiving spans
+	stopWatching := make(chan struct{})
+	if config.TraceTimeout > 0 {
+		storage.OnTraceComplete(func(trace completedTrace) {
+			log.Printf("Trace %s complete: %d spans received over %v", trace.traceID, trace.spanCount, trace.duration)
+		})
+		go storage.WatchTraceCompletion(stopWatching)
+	}
+
+	// Tee received batches to a downstream backend
+	var forwarder *traceForwarder
+	if config.ForwardTo != "" {
+		var err error
+		forwarder, err = newTraceForwarder(config.ForwardTo)
+		if err != nil {
+			log.Fatalf("Failed to set up forwarding: %v", err)
+		}
+	}
+
+	// Bind both transports before starting either, so a taken port is
+	// reported up front instead of leaving one transport silently dead
+	var grpcListener, httpListener net.Listener
+	var mux cmux.CMux
+	if config.SinglePort > 0 {
+		listener, err := listen("tcp", config.GRPCAddr())
+		if err != nil {
+			log.Fatalf("Failed to start: could not bind %s: %v", config.GRPCAddr(), err)
+		}
+		mux, grpcListener, httpListener = multiplexListener(listener)
+	} else {
+		var grpcErr, httpErr error
+		grpcListener, grpcErr = listen(config.GRPCNetwork(), config.GRPCAddr())
+		httpListener, httpErr = listen(config.HTTPNetwork(), config.HTTPAddr())
+		if err := checkListeners(config, grpcErr, httpErr); err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+	}
+
 	// Setup gRPC server for OTLP
-	grpcServer, grpcListener := setupGRPCServer(storage, config)
+	grpcServer := setupGRPCServer(storage, forwarder, config)
 
 	// Setup HTTP server for OTLP
-	httpServer := setupHTTPServer(storage, config)
+	httpServer := setupHTTPServer(storage, forwarder, config)
 
 	// Start servers
-	go func() {
-		log.Printf("Starting gRPC server on %s", config.GRPCAddr())
-		if err := grpcServer.Serve(grpcListener); err != nil {
-			log.Printf("gRPC server error: %v", err)
-		}
-	}()
+	if grpcListener != nil {
+		go func() {
+			log.Printf("Starting gRPC server on %s", config.GRPCAddr())
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+	}
+
+	if httpListener != nil {
+		go func() {
+			log.Printf("Starting HTTP server on %s", config.HTTPAddr())
+			// With -single-port, stopping the gRPC server closes the shared listener
+			if err := httpServer.Serve(httpListener); err != nil && err != http.ErrServerClosed && !errors.Is(err, cmux.ErrServerClosed) {
+				log.Printf("HTTP server error: %v", err)
+			}
+		}()
+	}
+
+	if mux != nil {
+		go func() {
+			if err := mux.Serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Multiplexed listener error: %v", err)
+			}
+		}()
+	}
 
+	// Periodically log which exporters are sending traces
+	if config.SenderSummaryInterval > 0 {
+		go logSenderSummaries(storage, config.SenderSummaryInterval, stopWatching)
+	}
+
+	// Periodically log what arrived since the last update
+	if config.Follow > 0 {
+		go followTraces(storage, config.Follow, stopWatching)
+	}
+
+	// Clear stored traces on SIGUSR1
+	clearChan := make(chan os.Signal, 1)
+	notifyClearSignal(clearChan)
 	go func() {
-		log.Printf("Starting HTTP server on %s", config.HTTPAddr())
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
+		for range clearChan {
+			cleared := storage.Clear()
+			log.Printf("Cleared %d stored trace batches (SIGUSR1)", cleared)
 		}
 	}()
 
@@ -62,20 +156,43 @@ func main() {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
+	// tracedown keeps handling the signals, so a repeated SIGTERM (e.g. from
+	// an impatient orchestrator) can't interrupt the report write
+	go func() {
+		for sig := range sigChan {
+			log.Printf("Received %v during shutdown, still writing reports", sig)
+		}
+	}()
+
 	log.Println("\nShutting down gracefully...")
+	close(stopWatching)
 
 	// Print final statistics
 	batches, spans, dropped, expired, memMB := storage.GetStats()
 	log.Printf("Final statistics:")
-	log.Printf("  Trace batches: %d", batches)
-	log.Printf("  Total spans: %d", spans)
+	lifetimeBatches, lifetimeSpans := storage.LifetimeStats()
+	log.Printf("  Trace batches: %d stored (%d received over the run)", batches, lifetimeBatches)
+	log.Printf("  Total spans: %d stored (%d received over the run)", spans, lifetimeSpans)
 	log.Printf("  Memory used: ~%.2f MB", memMB)
+	if config.ReportRealMemory {
+		logRealMemory()
+	}
 	if dropped > 0 {
 		log.Printf("  Traces dropped (limit): %d", dropped)
 	}
 	if expired > 0 {
 		log.Printf("  Traces expired (age): %d", expired)
 	}
+	if filtered := storage.FilteredSpans(); filtered > 0 {
+		log.Printf("  Spans dropped (match rules): %d", filtered)
+	}
+	if sampled := storage.SampledSpans(); sampled > 0 {
+		log.Printf("  Spans dropped (per-trace limit): %d", sampled)
+	}
+	if config.TraceTimeout > 0 {
+		log.Printf("  Traces completed (timeout): %d", storage.CompletedTraces())
+	}
+	logSenders(storage.GetSenders())
 
 	// Shutdown servers
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -85,39 +202,129 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	if mux != nil {
+		mux.Close()
+	}
 
-	// Generate markdown from collected traces
-	if err := storage.WriteMarkdown(config); err != nil {
-		log.Fatalf("Failed to write markdown: %v", err)
+	// Flush batches still queued for the downstream backend
+	if forwarder != nil {
+		forwarder.Close(5 * time.Second)
+		forwarded, failed, dropped := forwarder.Stats()
+		log.Printf("Forwarded %d batches to %s (%d failed, %d dropped)", forwarded, config.ForwardTo, failed, dropped)
 	}
 
-	log.Printf("Trace report written to %s", config.OutputFile)
+	writeReportsAndCheck(storage, config)
 }
 
-func setupGRPCServer(storage *TraceStorage, config *Config) (*grpc.Server, net.Listener) {
-	listener, err := net.Listen("tcp", config.GRPCAddr())
+// writeReportsAndCheck writes the configured reports and exits non-zero when
+// the collected traces violate a CI assertion
+func writeReportsAndCheck(storage *TraceStorage, config *Config) {
+	written, err := storage.WriteReports(config)
+	for _, path := range written {
+		log.Printf("Trace report written to %s", path)
+	}
 	if err != nil {
-		log.Fatalf("Failed to listen on %s: %v", config.GRPCAddr(), err)
+		log.Fatalf("Failed to write reports: %v", err)
+	}
+
+	// Fail the run for CI when the collected traces violate an assertion
+	if failures := storage.CheckAssertions(config); len(failures) > 0 {
+		for _, failure := range failures {
+			log.Printf("Assertion failed: %s", failure)
+		}
+		os.Exit(1)
+	}
+}
+
+// logSenderSummaries logs the connected senders every interval until stop is closed
+func logSenderSummaries(storage *TraceStorage, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if senders := storage.GetSenders(); len(senders) > 0 {
+				log.Printf("Connected senders summary:")
+				logSenders(senders)
+			}
+		}
 	}
+}
 
-	server := grpc.NewServer()
-	ptraceotlp.RegisterGRPCServer(server, &grpcTraceReceiver{storage: storage})
+// logRealMemory logs the Go runtime's actual memory use next to the storage
+// estimate so -max-memory-mb can be calibrated
+func logRealMemory() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	log.Printf("  Actual heap in use: %.2f MB (runtime total from OS: %.2f MB)",
+		float64(m.HeapAlloc)/(1024*1024), float64(m.Sys)/(1024*1024))
+}
 
-	return server, listener
+func logSenders(senders []senderInfo) {
+	log.Printf("  Connected senders: %d", len(senders))
+	for _, sender := range senders {
+		log.Printf("    %s via %s (user-agent: %s): %d requests, last seen %s",
+			sender.host, sender.protocol, sender.userAgent, sender.requests, sender.lastSeen.Format(time.RFC3339))
+	}
 }
 
-func setupHTTPServer(storage *TraceStorage, config *Config) *http.Server {
+// checkListeners reports bind failures for both transports. With
+// -allow-partial, startup continues as long as one transport is listening.
+func checkListeners(config *Config, grpcErr, httpErr error) error {
+	if grpcErr == nil && httpErr == nil {
+		return nil
+	}
+
+	var failures []string
+	if grpcErr != nil {
+		failures = append(failures, fmt.Sprintf("gRPC endpoint %s: %v", config.GRPCAddr(), grpcErr))
+	}
+	if httpErr != nil {
+		failures = append(failures, fmt.Sprintf("HTTP endpoint %s: %v", config.HTTPAddr(), httpErr))
+	}
+
+	if config.AllowPartial && (grpcErr == nil || httpErr == nil) {
+		for _, failure := range failures {
+			log.Printf("Warning: %s (continuing with -allow-partial)", failure)
+		}
+		return nil
+	}
+	return fmt.Errorf("could not bind %s", strings.Join(failures, "; "))
+}
+
+func setupGRPCServer(storage *TraceStorage, forwarder *traceForwarder, config *Config) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcAuthInterceptor(config)),
+		grpc.UnknownServiceHandler(unknownServiceHandler),
+	)
+	ptraceotlp.RegisterGRPCServer(server, &grpcTraceReceiver{storage: storage, forwarder: forwarder})
+
+	return server
+}
+
+func setupHTTPServer(storage *TraceStorage, forwarder *traceForwarder, config *Config) *http.Server {
 	mux := http.NewServeMux()
 
 	// OTLP/HTTP endpoint
-	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/v1/traces", requireAuth(config, limitConcurrency(config.MaxConcurrentHTTP, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			log.Printf("HTTP: Method not allowed: %s from %s", r.Method, r.RemoteAddr)
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		receiver := &httpTraceReceiver{storage: storage}
+		if isArrowContentType(r.Header.Get("Content-Type")) {
+			log.Printf("HTTP: Rejected OTLP Arrow request from %s: Arrow is not supported", r.RemoteAddr)
+			http.Error(w, arrowUnsupportedMessage, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		storage.RecordSender("http", r.RemoteAddr, r.UserAgent())
+
+		receiver := &httpTraceReceiver{storage: storage, forwarder: forwarder}
 		req := ptraceotlp.NewExportRequest()
 
 		body, err := io.ReadAll(r.Body)
@@ -126,6 +333,7 @@ func setupHTTPServer(storage *TraceStorage, config *Config) *http.Server {
 			http.Error(w, fmt.Sprintf("Failed to read request body: %v", err), http.StatusBadRequest)
 			return
 		}
+		storage.config.debugf("HTTP: Request from %s: %d bytes (%s)", r.RemoteAddr, len(body), r.Header.Get("Content-Type"))
 
 		if err := req.UnmarshalProto(body); err != nil {
 			log.Printf("HTTP: Failed to parse OTLP request from %s: %v", r.RemoteAddr, err)
@@ -140,32 +348,87 @@ func setupHTTPServer(storage *TraceStorage, config *Config) *http.Server {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/x-protobuf")
-		w.WriteHeader(http.StatusOK)
+		writeExportResponse(w, r.RemoteAddr, resp.MarshalProto)
+	})))
 
-		data, err := resp.MarshalProto()
-		if err != nil {
-			log.Printf("HTTP: Failed to marshal response: %v", err)
-			return
-		}
-		w.Write(data)
-	})
+	// Runtime control API
+	registerAPIHandlers(mux, storage, config)
 
-	return &http.Server{
+	server := &http.Server{
 		Addr:    config.HTTPAddr(),
 		Handler: mux,
 	}
+	// Accept HTTP/2 without TLS (h2c) alongside HTTP/1.1 for proxies and
+	// load balancers that only speak HTTP/2 to backends
+	if config.H2C {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return server
+}
+
+// writeExportResponse writes a successful OTLP/HTTP export response. marshal
+// runs before the status is written so a failure can still be reported as a
+// 500 instead of an empty 200. The traces are already stored, so the client
+// may resend and produce duplicates.
+func writeExportResponse(w http.ResponseWriter, remoteAddr string, marshal func() ([]byte, error)) {
+	data, err := marshal()
+	if err != nil {
+		log.Printf("HTTP: Failed to marshal response to %s: %v", remoteAddr, err)
+		http.Error(w, fmt.Sprintf("Failed to marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// multiplexListener splits one listener into gRPC and HTTP listeners by
+// sniffing each connection: HTTP/2 requests with a gRPC content type go to
+// the gRPC server, everything else to the HTTP server
+func multiplexListener(listener net.Listener) (cmux.CMux, net.Listener, net.Listener) {
+	mux := cmux.New(listener)
+	grpcListener := mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	httpListener := mux.Match(cmux.Any())
+	return mux, grpcListener, httpListener
+}
+
+// listen opens a listener, replacing a stale unix socket left by a previous run
+func listen(network, addr string) (net.Listener, error) {
+	if network == "unix" {
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(addr); err != nil {
+				return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+			}
+		}
+	}
+	return net.Listen(network, addr)
 }
 
 // grpcTraceReceiver implements the gRPC OTLP trace receiver
 type grpcTraceReceiver struct {
 	ptraceotlp.UnimplementedGRPCServer
-	storage *TraceStorage
+	storage   *TraceStorage
+	forwarder *traceForwarder
 }
 
 func (r *grpcTraceReceiver) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	remoteAddr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	userAgent := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		userAgent = strings.Join(md.Get("user-agent"), " ")
+	}
+	r.storage.RecordSender("grpc", remoteAddr, userAgent)
+	r.storage.config.debugf("gRPC: Export from %s: %d spans", remoteAddr, req.Traces().SpanCount())
+
 	traces := req.Traces()
 	r.storage.AddTraces(traces)
+	r.forwarder.Forward(traces)
 	return ptraceotlp.NewExportResponse(), nil
 }
 
@@ -173,11 +436,13 @@ func (r *grpcTraceReceiver) MustEmbedUnimplementedGRPCServer() {}
 
 // httpTraceReceiver handles HTTP OTLP trace requests
 type httpTraceReceiver struct {
-	storage *TraceStorage
+	storage   *TraceStorage
+	forwarder *traceForwarder
 }
 
 func (r *httpTraceReceiver) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
 	traces := req.Traces()
 	r.storage.AddTraces(traces)
+	r.forwarder.Forward(traces)
 	return ptraceotlp.NewExportResponse(), nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// statusRecorder records every status written, so a 500 written after an
+// already-sent 200 can't go unnoticed
+type statusRecorder struct {
+	*httptest.ResponseRecorder
+	statuses []int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.statuses = append(r.statuses, code)
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+func TestWriteExportResponse(t *testing.T) {
+	t.Run("marshal failure", func(t *testing.T) {
+		rec := &statusRecorder{ResponseRecorder: httptest.NewRecorder()}
+		writeExportResponse(rec, "127.0.0.1:1234", func() ([]byte, error) {
+			return nil, errors.New("boom")
+		})
+		if len(rec.statuses) != 1 || rec.statuses[0] != http.StatusInternalServerError {
+			t.Fatalf("statuses written = %v, want only %d", rec.statuses, http.StatusInternalServerError)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "Failed to marshal response: boom") {
+			t.Errorf("body = %q, want the marshal error", body)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		rec := &statusRecorder{ResponseRecorder: httptest.NewRecorder()}
+		writeExportResponse(rec, "127.0.0.1:1234", func() ([]byte, error) {
+			return []byte("ok"), nil
+		})
+		if len(rec.statuses) != 1 || rec.statuses[0] != http.StatusOK {
+			t.Fatalf("statuses written = %v, want only %d", rec.statuses, http.StatusOK)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q, want application/x-protobuf", got)
+		}
+		if rec.Body.String() != "ok" {
+			t.Errorf("body = %q, want the marshalled response", rec.Body.String())
+		}
+	})
+}
@@ -1,52 +1,193 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io"
+	"math"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
-// WriteMarkdown generates a markdown file from stored traces
-func (s *TraceStorage) WriteMarkdown(config *Config) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// writeInternalStats writes the overview rows about collection itself:
+// drops, filtering, data quality and arrival rate. -hide-internal-stats
+// leaves them out of reports shared outside the team.
+func (s *storageSnapshot) writeInternalStats(f io.Writer, traces []*traceInfo, filtered int, config *Config) {
+	fmt.Fprintf(f, "| Batches Stored | %d |\n", len(s.traces))
+	if s.lifetimeBatches != len(s.traces) {
+		fmt.Fprintf(f, "| Batches Received (whole run) | %d |\n", s.lifetimeBatches)
+		fmt.Fprintf(f, "| Spans Received (whole run) | %d |\n", s.lifetimeSpans)
+	}
 
-	f, err := os.Create(config.OutputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	totalDropped := s.droppedOldest + s.droppedTraces
+	if totalDropped > 0 {
+		fmt.Fprintf(f, "| Traces Dropped | %d |\n", totalDropped)
+	}
+	if s.filteredSpans > 0 {
+		fmt.Fprintf(f, "| Spans Dropped (match rules) | %d |\n", s.filteredSpans)
+	}
+	if s.sampledSpans > 0 {
+		fmt.Fprintf(f, "| Spans Dropped (per-trace limit) | %d |\n", s.sampledSpans)
 	}
-	defer f.Close()
 
+	if filtered > 0 {
+		fmt.Fprintf(f, "| Traces Filtered (< %d spans) | %d |\n", config.MinSpans, filtered)
+	}
+	if incomplete := countIncompleteTraces(traces, config); incomplete > 0 {
+		fmt.Fprintf(f, "| Traces Missing Required Spans | %d |\n", incomplete)
+	}
+	if skewed := countSkewedTraces(traces); skewed > 0 {
+		fmt.Fprintf(f, "| Traces with Spans Before Root | %d |\n", skewed)
+	}
+	if s.malformedTraceIDSpans > 0 {
+		fmt.Fprintf(f, "| Spans with Malformed Trace ID | %d |\n", s.malformedTraceIDSpans)
+	}
+	if rate, ok := computeArrivalRate(traces); ok {
+		fmt.Fprintf(f, "| Arrival Rate (traces/min) | %s |\n", rate)
+	}
+}
+
+// WriteMarkdown renders the stored traces as a markdown report
+func (s *storageSnapshot) WriteMarkdown(f io.Writer, config *Config) error {
 	// Write header
-	fmt.Fprintf(f, "# OpenTelemetry Traces Report\n\n")
+	fmt.Fprintf(f, "# %s\n\n", config.ReportTitle)
 
 	// Write overview table
 	fmt.Fprintf(f, "## Overview\n\n")
 	fmt.Fprintf(f, "| Metric | Value |\n")
 	fmt.Fprintf(f, "|--------|-------|\n")
 	fmt.Fprintf(f, "| Generated | %s |\n", time.Now().Format(time.RFC3339))
-	fmt.Fprintf(f, "| Total Traces | %d |\n", len(s.traces))
-
-	totalDropped := s.droppedOldest + s.droppedTraces
-	if totalDropped > 0 {
-		fmt.Fprintf(f, "| Traces Dropped | %d |\n", totalDropped)
+	traces, filtered := s.reportTraces(config)
+	fmt.Fprintf(f, "| Total Traces | %d |\n", len(traces))
+	if rate, ok := errorRate(traces); ok {
+		fmt.Fprintf(f, "| Error Rate | %s |\n", rate)
+	}
+	if !config.HideInternalStats {
+		s.writeInternalStats(f, traces, filtered, config)
+	}
+	for _, key := range config.overviewLabels {
+		if counts := labelDistribution(traces, key); counts != "" {
+			fmt.Fprintf(f, "| Traces by %s | %s |\n", escapeTableCell(codeSpan(key)), escapeTableCell(counts))
+		}
 	}
 	fmt.Fprintf(f, "\n")
+	if config.ReportNote != "" {
+		fmt.Fprintf(f, "%s\n\n", config.ReportNote)
+	}
 
 	if len(s.traces) == 0 {
 		fmt.Fprintf(f, "No traces were collected.\n")
 		return nil
 	}
+	writeMalformedTraceIDs(f, s.traces, config)
+	if len(traces) == 0 {
+		fmt.Fprintf(f, "No traces matched the report filters.\n")
+		return nil
+	}
+
+	if config.StatsOnly {
+		writeServiceStats(f, traces, config)
+		writeOperationStats(f, traces, config)
+		writeHighCardinalityAttributes(f, traces, config)
+		writeErrorSummary(f, traces, config)
+		return nil
+	}
+
+	// With -group-by, number traces group by group so the sections of one
+	// group are adjacent
+	var groups []traceGroup
+	if config.groupByKey != "" {
+		groups = groupTracesBy(traces, config.groupByKey)
+		traces = orderByGroup(groups)
+	}
+
+	writeLargeTraces(f, traces, config)
+	writeServiceStats(f, traces, config)
+	writeOperationStats(f, traces, config)
+	writeHighCardinalityAttributes(f, traces, config)
+
+	if groups != nil {
+		return writeGroupedTraces(f, traces, groups, config)
+	}
+
+	// Group traces by status for TOC
+	errorTraces := []*traceInfo{}
+	successTraces := []*traceInfo{}
+
+	for _, ti := range traces {
+		if ti.hasError() {
+			errorTraces = append(errorTraces, ti)
+		} else {
+			successTraces = append(successTraces, ti)
+		}
+	}
+
+	// Write Table of Contents
+	fmt.Fprintf(f, "## Table of Contents\n\n")
 
-	// Collect all spans across all traces for grouping by trace ID
+	if len(errorTraces) > 0 {
+		prioritizeCriticalTraces(errorTraces, config)
+		fmt.Fprintf(f, "### ⚠️ Traces with Errors (%d)\n", len(errorTraces))
+		writeTOCTable(f, traces, errorTraces, config)
+		fmt.Fprintf(f, "\n")
+	}
+
+	if len(successTraces) > 0 {
+		fmt.Fprintf(f, "### ✓ Successful Traces (%d)\n", len(successTraces))
+		writeTOCTable(f, traces, successTraces, config)
+		fmt.Fprintf(f, "\n")
+	}
+
+	fmt.Fprintf(f, "---\n\n")
+
+	// Write each trace
+	return renderTraces(f, traces, config, func(w io.Writer, index int, ti *traceInfo) {
+		writeTrace(w, index, ti, config)
+	}, func(w io.Writer, index int, ti *traceInfo, reason string) {
+		writeTraceFailure(w, index, ti, reason, config)
+	})
+}
+
+type traceInfo struct {
+	traceID string
+	spans   []spanInfo
+
+	// Arrival metadata carried over from the stored batches: when the
+	// trace's first and last spans were received and how many batches
+	// contained its spans
+	firstArrival time.Time
+	lastArrival  time.Time
+	batches      int
+
+	// Set when -split-collisions separated unrelated requests sharing the
+	// trace ID: this part's suffix ("a", "b", ...) and the number of parts
+	collisionPart  string
+	collisionParts int
+}
+
+type spanInfo struct {
+	span              ptrace.Span
+	resource          pcommon.Resource
+	resourceSchemaURL string // semantic convention version of the resource
+	scope             pcommon.InstrumentationScope
+	scopeSchemaURL    string
+}
+
+// groupTraces collects spans from all stored batches, grouped by trace ID.
+// Traces are ordered by their first span start time and spans within each
+// trace by start time, so every output format renders them identically.
+func groupTraces(entries []traceEntry) []*traceInfo {
 	traceMap := make(map[string]*traceInfo)
+	lastBatch := make(map[string]int)
 
-	for _, entry := range s.traces {
+	for n, entry := range entries {
 		traces := entry.traces
 		for i := 0; i < traces.ResourceSpans().Len(); i++ {
 			rs := traces.ResourceSpans().At(i)
@@ -58,94 +199,173 @@ func (s *TraceStorage) WriteMarkdown(config *Config) error {
 
 				for k := 0; k < ss.Spans().Len(); k++ {
 					span := ss.Spans().At(k)
+					// An all-zero trace ID would merge unrelated spans into
+					// one fake trace; those are listed by malformedTraceIDSpans
+					if span.TraceID().IsEmpty() {
+						continue
+					}
 					traceID := span.TraceID().String()
 
 					if _, exists := traceMap[traceID]; !exists {
 						traceMap[traceID] = &traceInfo{
-							traceID: traceID,
-							spans:   []spanInfo{},
+							traceID:      traceID,
+							spans:        []spanInfo{},
+							firstArrival: entry.timestamp,
 						}
 					}
+					ti := traceMap[traceID]
+					if entry.timestamp.Before(ti.firstArrival) {
+						ti.firstArrival = entry.timestamp
+					}
+					if entry.timestamp.After(ti.lastArrival) {
+						ti.lastArrival = entry.timestamp
+					}
+					if last, seen := lastBatch[traceID]; !seen || last != n {
+						lastBatch[traceID] = n
+						ti.batches++
+					}
 
-					traceMap[traceID].spans = append(traceMap[traceID].spans, spanInfo{
-						span:     span,
-						resource: resource,
-						scope:    scope,
+					ti.spans = append(ti.spans, spanInfo{
+						span:              span,
+						resource:          resource,
+						resourceSchemaURL: rs.SchemaUrl(),
+						scope:             scope,
+						scopeSchemaURL:    ss.SchemaUrl(),
 					})
 				}
 			}
 		}
 	}
 
-	// Sort traces by first span start time
+	// Sort traces by first span start time, breaking ties by trace ID so
+	// reports are byte-stable for the same input
 	traces := make([]*traceInfo, 0, len(traceMap))
 	for _, ti := range traceMap {
+		sort.Slice(ti.spans, func(i, j int) bool {
+			return spanBefore(ti.spans[i].span, ti.spans[j].span)
+		})
 		traces = append(traces, ti)
 	}
 	sort.Slice(traces, func(i, j int) bool {
-		return traces[i].getEarliestTime() < traces[j].getEarliestTime()
+		if ei, ej := traces[i].getEarliestTime(), traces[j].getEarliestTime(); ei != ej {
+			return ei < ej
+		}
+		return traces[i].traceID < traces[j].traceID
 	})
 
-	// Group traces by status for TOC
-	errorTraces := []*traceInfo{}
-	successTraces := []*traceInfo{}
+	return traces
+}
+
+// spanBefore orders spans by start time, then span ID, name and end time, so
+// spans starting at the same instant (common with coarse clocks) always come
+// out in the same order
+func spanBefore(a, b ptrace.Span) bool {
+	if a.StartTimestamp() != b.StartTimestamp() {
+		return a.StartTimestamp() < b.StartTimestamp()
+	}
+	aID, bID := a.SpanID(), b.SpanID()
+	if c := bytes.Compare(aID[:], bID[:]); c != 0 {
+		return c < 0
+	}
+	if a.Name() != b.Name() {
+		return a.Name() < b.Name()
+	}
+	return a.EndTimestamp() < b.EndTimestamp()
+}
 
+// findLargeTraces returns the traces with more than threshold spans, which
+// often point at runaway instrumentation, largest first
+func findLargeTraces(traces []*traceInfo, threshold int) []*traceInfo {
+	if threshold <= 0 {
+		return nil
+	}
+	var large []*traceInfo
 	for _, ti := range traces {
-		if ti.hasError() {
-			errorTraces = append(errorTraces, ti)
-		} else {
-			successTraces = append(successTraces, ti)
+		if len(ti.spans) > threshold {
+			large = append(large, ti)
 		}
 	}
+	sort.SliceStable(large, func(i, j int) bool {
+		return len(large[i].spans) > len(large[j].spans)
+	})
+	return large
+}
 
-	// Write Table of Contents
-	fmt.Fprintf(f, "## Table of Contents\n\n")
+// writeLargeTraces calls out traces exceeding -span-count-warn
+func writeLargeTraces(f io.Writer, traces []*traceInfo, config *Config) {
+	large := findLargeTraces(traces, config.SpanCountWarn)
+	if len(large) == 0 {
+		return
+	}
 
-	if len(errorTraces) > 0 {
-		fmt.Fprintf(f, "### ⚠️ Traces with Errors (%d)\n", len(errorTraces))
-		fmt.Fprintf(f, "| Trace | Service | Duration | Spans | Root Operation | Status |\n")
-		fmt.Fprintf(f, "|-------|---------|----------|-------|----------------|--------|\n")
-		for _, ti := range errorTraces {
-			traceNum := findTraceIndex(traces, ti) + 1
-			writeTOCRow(f, traceNum, ti)
-		}
-		fmt.Fprintf(f, "\n")
+	fmt.Fprintf(f, "## ⚠️ Unusually Large Traces\n\n")
+	fmt.Fprintf(f, "%d trace(s) have more than %d spans, which often indicates an instrumentation bug such as spans created in a loop.\n\n", len(large), config.SpanCountWarn)
+	table := newMarkdownTable("Trace", "Trace ID", "Spans", "Root Operation")
+	numbers := traceNumbers(traces)
+	for _, ti := range large {
+		traceNum := numbers[ti]
+		table.addRow(fmt.Sprintf("[#%d](#%s)", traceNum, traceAnchor(traceNum, ti, config)), "`"+ti.partID()+"`", fmt.Sprintf("%d", len(ti.spans)), escapeMarkdown(ti.getRootSpanName()))
 	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
 
-	if len(successTraces) > 0 {
-		fmt.Fprintf(f, "### ✓ Successful Traces (%d)\n", len(successTraces))
-		fmt.Fprintf(f, "| Trace | Service | Duration | Spans | Root Operation | Status |\n")
-		fmt.Fprintf(f, "|-------|---------|----------|-------|----------------|--------|\n")
-		for _, ti := range successTraces {
-			traceNum := findTraceIndex(traces, ti) + 1
-			writeTOCRow(f, traceNum, ti)
+// malformedTraceIDSpans returns the spans with an all-zero trace ID, which
+// groupTraces leaves out
+func malformedTraceIDSpans(entries []traceEntry) []spanInfo {
+	var spans []spanInfo
+	for _, entry := range entries {
+		for i := 0; i < entry.traces.ResourceSpans().Len(); i++ {
+			rs := entry.traces.ResourceSpans().At(i)
+			for j := 0; j < rs.ScopeSpans().Len(); j++ {
+				ss := rs.ScopeSpans().At(j)
+				for k := 0; k < ss.Spans().Len(); k++ {
+					if span := ss.Spans().At(k); span.TraceID().IsEmpty() {
+						spans = append(spans, spanInfo{span: span, resource: rs.Resource(), resourceSchemaURL: rs.SchemaUrl(), scope: ss.Scope(), scopeSchemaURL: ss.SchemaUrl()})
+					}
+				}
+			}
 		}
-		fmt.Fprintf(f, "\n")
 	}
+	return spans
+}
 
-	fmt.Fprintf(f, "---\n\n")
+// writeMalformedTraceIDs lists spans that can't be grouped into a trace
+// because their trace ID is all zeros
+func writeMalformedTraceIDs(f io.Writer, entries []traceEntry, config *Config) {
+	spans := malformedTraceIDSpans(entries)
+	if len(spans) == 0 {
+		return
+	}
 
-	// Write each trace
-	for idx, ti := range traces {
-		if config.SummaryMode {
-			writeTraceSummary(f, idx+1, ti, config)
-		} else {
-			writeTrace(f, idx+1, ti)
+	fmt.Fprintf(f, "## ⚠️ Spans with Malformed Trace IDs\n\n")
+	fmt.Fprintf(f, "%d span(s) have an all-zero trace ID, usually a broken exporter or context propagation. They aren't grouped into a trace since they can't be told apart.\n\n", len(spans))
+	table := newMarkdownTable("Service", "Span", "Span ID", "Parent Span ID")
+	for _, si := range spans {
+		service, _ := spanServiceName(si.resource, si.span, config)
+		parentID := "-"
+		if !si.span.ParentSpanID().IsEmpty() {
+			parentID = "`" + si.span.ParentSpanID().String() + "`"
 		}
+		table.addRow(escapeMarkdown(service), escapeMarkdown(si.span.Name()), "`"+si.span.SpanID().String()+"`", parentID)
 	}
-
-	return nil
-}
-
-type traceInfo struct {
-	traceID string
-	spans   []spanInfo
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
 }
 
-type spanInfo struct {
-	span     ptrace.Span
-	resource pcommon.Resource
-	scope    pcommon.InstrumentationScope
+// filterMinSpans drops traces with fewer than minSpans spans and returns
+// how many were filtered out
+func filterMinSpans(traces []*traceInfo, minSpans int) ([]*traceInfo, int) {
+	if minSpans <= 0 {
+		return traces, 0
+	}
+	kept := make([]*traceInfo, 0, len(traces))
+	for _, ti := range traces {
+		if len(ti.spans) >= minSpans {
+			kept = append(kept, ti)
+		}
+	}
+	return kept, len(traces) - len(kept)
 }
 
 func (ti *traceInfo) getEarliestTime() uint64 {
@@ -170,6 +390,51 @@ func (ti *traceInfo) hasError() bool {
 	return false
 }
 
+// countErrorTraces returns how many traces contain an error span
+func countErrorTraces(traces []*traceInfo) int {
+	count := 0
+	for _, ti := range traces {
+		if ti.hasError() {
+			count++
+		}
+	}
+	return count
+}
+
+// errorRate formats the share of traces containing an error span with the
+// counts behind it, e.g. "12.5% (1 of 8 traces)"
+func errorRate(traces []*traceInfo) (string, bool) {
+	if len(traces) == 0 {
+		return "", false
+	}
+	failed := countErrorTraces(traces)
+	return fmt.Sprintf("%.1f%% (%d of %d traces)", 100*float64(failed)/float64(len(traces)), failed, len(traces)), true
+}
+
+// maxStatusSnippet is how many characters of an error message the TOC shows
+const maxStatusSnippet = 40
+
+// firstErrorMessage returns the status message of the earliest error span
+// that has one, collapsed onto a single line
+func (ti *traceInfo) firstErrorMessage() string {
+	for _, si := range ti.spans {
+		status := si.span.Status()
+		if status.Code() == ptrace.StatusCodeError && status.Message() != "" {
+			return strings.Join(strings.Fields(status.Message()), " ")
+		}
+	}
+	return ""
+}
+
+// truncateText shortens s to at most limit characters, marking the cut with …
+func truncateText(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
 func (ti *traceInfo) getDuration() time.Duration {
 	if len(ti.spans) == 0 {
 		return 0
@@ -187,147 +452,795 @@ func (ti *traceInfo) getDuration() time.Duration {
 	return time.Duration(latest - earliest)
 }
 
-func (ti *traceInfo) getServiceName() string {
+func (ti *traceInfo) getServiceName(config *Config) string {
 	if len(ti.spans) == 0 {
 		return "unknown"
 	}
-	if serviceName, ok := ti.spans[0].resource.Attributes().Get("service.name"); ok {
-		return serviceName.AsString()
+	name, _ := spanServiceName(ti.spans[0].resource, ti.spans[0].span, config)
+	return name
+}
+
+// defaultServiceNameFallback is the resource attribute chain used to name a
+// service when -service-name-fallback isn't set
+const defaultServiceNameFallback = "service.name,k8s.deployment.name,process.executable.name"
+
+// resourceServiceName returns the first non-empty value among the resource
+// attribute keys, and the key it came from, or "unknown" if none is set
+func resourceServiceName(resource pcommon.Resource, keys []string) (string, string) {
+	for _, key := range keys {
+		if value, ok := resource.Attributes().Get(key); ok && value.AsString() != "" {
+			return value.AsString(), key
+		}
 	}
-	return "unknown"
+	return "unknown", ""
 }
 
-func (ti *traceInfo) getRootSpanName() string {
-	// Find the span with no parent (root span)
-	for _, si := range ti.spans {
-		if si.span.ParentSpanID().IsEmpty() {
-			return si.span.Name()
+// spanServiceName names a span's service from its resource, falling back to
+// the -service-name-span-attr attribute on the span itself for data that
+// doesn't follow the resource convention. It also returns the attribute key
+// the name came from.
+func spanServiceName(resource pcommon.Resource, span ptrace.Span, config *Config) (string, string) {
+	name, key := resourceServiceName(resource, config.serviceNameKeys)
+	if key == "" && config.ServiceNameSpanAttr != "" {
+		if value, ok := span.Attributes().Get(config.ServiceNameSpanAttr); ok && value.AsString() != "" {
+			return value.AsString(), config.ServiceNameSpanAttr
 		}
 	}
-	// If no root found, return first span name
-	if len(ti.spans) > 0 {
-		return ti.spans[0].span.Name()
+	return name, key
+}
+
+// getEnvironment returns the deployment environment from the resource
+// attributes, or "" when the trace doesn't declare one
+func (ti *traceInfo) getEnvironment() string {
+	if len(ti.spans) == 0 {
+		return ""
+	}
+	attrs := ti.spans[0].resource.Attributes()
+	for _, key := range []string{"deployment.environment.name", "deployment.environment"} {
+		if env, ok := attrs.Get(key); ok && env.AsString() != "" {
+			return env.AsString()
+		}
+	}
+	return ""
+}
+
+// envBadge prefixes well-known environment names with a colored marker so
+// production traces stand out in the TOC
+func envBadge(env string) string {
+	switch strings.ToLower(env) {
+	case "":
+		return "-"
+	case "prod", "production", "prd", "live":
+		return "🔴 " + env
+	case "staging", "stage", "stg", "preprod", "uat":
+		return "🟡 " + env
+	case "dev", "development", "local", "test", "testing", "qa":
+		return "🟢 " + env
+	default:
+		return "⚪ " + env
+	}
+}
+
+// anyEnvironment reports whether any trace declares a deployment environment
+func anyEnvironment(traces []*traceInfo) bool {
+	for _, ti := range traces {
+		if ti.getEnvironment() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (ti *traceInfo) getRootSpanName() string {
+	if root, ok := ti.findRootSpan(); ok {
+		return root.span.Name()
 	}
 	return "unknown"
 }
 
-func findTraceIndex(traces []*traceInfo, target *traceInfo) int {
+// getTraceLabel composes the root span's values for the given attribute keys
+// into a display name, falling back to the root span name when none are set
+func (ti *traceInfo) getTraceLabel(attrKeys []string) string {
+	root, ok := ti.findRootSpan()
+	if !ok {
+		return "unknown"
+	}
+
+	var parts []string
+	for _, key := range attrKeys {
+		if val, ok := root.span.Attributes().Get(key); ok && val.AsString() != "" {
+			parts = append(parts, val.AsString())
+		}
+	}
+	if len(parts) == 0 {
+		return root.span.Name()
+	}
+	return strings.Join(parts, " ")
+}
+
+// maxOverviewLabelValues caps how many values of an -overview-labels
+// attribute are listed in the overview
+const maxOverviewLabelValues = 8
+
+// labelDistribution counts traces by the value of key on their root span,
+// falling back to its resource, e.g. "`/api/users` ×12, `/health` ×3".
+// Returns "" when no trace has the attribute.
+func labelDistribution(traces []*traceInfo, key string) string {
+	counts := make(map[string]int)
+	for _, ti := range traces {
+		root, ok := ti.findRootSpan()
+		if !ok {
+			continue
+		}
+		val, ok := root.span.Attributes().Get(key)
+		if !ok {
+			val, ok = root.resource.Attributes().Get(key)
+		}
+		if ok && val.AsString() != "" {
+			counts[val.AsString()]++
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	var parts []string
+	for _, value := range values[:min(len(values), maxOverviewLabelValues)] {
+		parts = append(parts, fmt.Sprintf("%s ×%d", codeSpan(value), counts[value]))
+	}
+	if hidden := len(values) - maxOverviewLabelValues; hidden > 0 {
+		parts = append(parts, fmt.Sprintf("… %d more", hidden))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// findRootSpan returns the span with no parent, preferring spans with a valid
+// span ID so an orphan with an empty ID can't masquerade as the root. Falls
+// back to the first span when no span is parentless.
+func (ti *traceInfo) findRootSpan() (spanInfo, bool) {
+	if len(ti.spans) == 0 {
+		return spanInfo{}, false
+	}
+
+	var emptyIDRoot *spanInfo
+	for i, si := range ti.spans {
+		if !si.span.ParentSpanID().IsEmpty() {
+			continue
+		}
+		if !si.span.SpanID().IsEmpty() {
+			return si, true
+		}
+		if emptyIDRoot == nil {
+			emptyIDRoot = &ti.spans[i]
+		}
+	}
+	if emptyIDRoot != nil {
+		return *emptyIDRoot, true
+	}
+	return ti.spans[0], true
+}
+
+// countEmptySpanIDs returns how many spans have an empty (all-zero) span ID
+func (ti *traceInfo) countEmptySpanIDs() int {
+	count := 0
+	for _, si := range ti.spans {
+		if si.span.SpanID().IsEmpty() {
+			count++
+		}
+	}
+	return count
+}
+
+// traceNumbers maps each trace to its 1-based section number. Traces are
+// keyed by pointer since -split-collisions parts share a trace ID.
+func traceNumbers(traces []*traceInfo) map[*traceInfo]int {
+	numbers := make(map[*traceInfo]int, len(traces))
 	for i, ti := range traces {
-		if ti.traceID == target.traceID {
-			return i
+		numbers[ti] = i + 1
+	}
+	return numbers
+}
+
+func writeTOCTable(f io.Writer, traces []*traceInfo, section []*traceInfo, config *Config) {
+	// Only add the environment column when some trace declares one
+	showEnv := anyEnvironment(traces)
+	headers := []string{"Trace", "Service", "Duration", "Spans", "Root Operation", "Status", "Received"}
+	if showEnv {
+		headers = []string{"Trace", "Env", "Service", "Duration", "Spans", "Root Operation", "Status", "Received"}
+	}
+	table := newMarkdownTable(headers...)
+	numbers := traceNumbers(traces)
+	for _, ti := range section {
+		traceNum := numbers[ti]
+		table.addRow(tocRowCells(traceNum, ti, showEnv, config)...)
+	}
+	table.write(f, config.Pretty)
+}
+
+// traceAnchor returns the link target of a trace's section header
+func traceAnchor(traceNum int, ti *traceInfo, config *Config) string {
+	// Header is: "## Trace 1: abc123" which becomes anchor: "trace-1-abc123"
+	return headingAnchor(traceHeading(traceNum, ti, config))
+}
+
+// traceHeading is the text of a trace's section header
+func traceHeading(traceNum int, ti *traceInfo, config *Config) string {
+	return fmt.Sprintf("Trace %d: %s", traceNum, ti.displayName(config))
+}
+
+// displayName returns the root span's -trace-name-attr value when it has
+// one, otherwise the trace ID, suffixed with the part of a split trace
+func (ti *traceInfo) displayName(config *Config) string {
+	if config.TraceNameAttr != "" {
+		if root, ok := ti.findRootSpan(); ok {
+			if val, ok := root.span.Attributes().Get(config.TraceNameAttr); ok && val.AsString() != "" {
+				return strings.Join(strings.Fields(val.AsString()), " ")
+			}
+		}
+	}
+	return ti.partID()
+}
+
+// partID returns the trace ID, suffixed with the part of a split trace
+func (ti *traceInfo) partID() string {
+	if ti.collisionPart != "" {
+		return ti.traceID + "-" + ti.collisionPart
+	}
+	return ti.traceID
+}
+
+// headingAnchor derives the anchor markdown renderers give a header:
+// lowercased, punctuation dropped and spaces replaced with hyphens
+func headingAnchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
 		}
 	}
-	return -1
+	return b.String()
 }
 
-func writeTOCRow(f *os.File, traceNum int, ti *traceInfo) {
+func tocRowCells(traceNum int, ti *traceInfo, showEnv bool, config *Config) []string {
 	duration := ti.getDuration()
-	serviceName := ti.getServiceName()
-	rootSpan := ti.getRootSpanName()
+	serviceName := ti.getServiceName(config)
+	rootSpan := ti.getTraceLabel(config.traceLabelAttrs)
 	status := "✓ OK"
 	if ti.hasError() {
 		status = "⚠️ ERROR"
+		if ti.hasCriticalError(config) {
+			status = criticalMarker + " CRITICAL ERROR"
+		}
+		if msg := ti.firstErrorMessage(); msg != "" {
+			status += ": " + escapeMarkdown(truncateText(msg, maxStatusSnippet))
+		}
+	}
+
+	cells := []string{fmt.Sprintf("[#%d](#%s)", traceNum, traceAnchor(traceNum, ti, config))}
+	if showEnv {
+		cells = append(cells, escapeMarkdown(envBadge(ti.getEnvironment())))
+	}
+	return append(cells,
+		escapeMarkdown(serviceName),
+		duration.String(),
+		fmt.Sprintf("%d", len(ti.spans)),
+		escapeMarkdown(rootSpan),
+		status,
+		formatReceived(ti.firstArrival, config),
+	)
+}
+
+// formatReceived renders when a trace arrived, either relative to now
+// ("12m ago") or as a local timestamp with -received-format absolute
+func formatReceived(arrival time.Time, config *Config) string {
+	if arrival.IsZero() {
+		return "-"
 	}
+	if config.ReceivedFormat == "absolute" {
+		return config.wallClock(arrival).Format("2006-01-02 15:04:05")
+	}
+
+	age := time.Since(arrival)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm ago", int(age.Hours()), int(age.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
+
+// markdownTable buffers rows so columns can optionally be padded to line up
+// in the raw markdown while remaining a valid GitHub table
+type markdownTable struct {
+	headers []string
+	rows    [][]string
+}
+
+func newMarkdownTable(headers ...string) *markdownTable {
+	return &markdownTable{headers: headers}
+}
+
+// addRow adds a row, escaping the cells with escapeTableCell
+func (t *markdownTable) addRow(cells ...string) {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = escapeTableCell(cell)
+	}
+	t.rows = append(t.rows, escaped)
+}
+
+// write renders the table; with pretty set, cells are padded to the widest
+// value in their column
+func (t *markdownTable) write(f io.Writer, pretty bool) {
+	widths := make([]int, len(t.headers))
+	for i, header := range t.headers {
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	if pretty {
+		for _, row := range t.rows {
+			for i, cell := range row {
+				if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+					widths[i] = utf8.RuneCountInString(cell)
+				}
+			}
+		}
+	}
+
+	writeRow := func(cells []string) {
+		var b strings.Builder
+		b.WriteString("|")
+		for i, cell := range cells {
+			b.WriteString(" ")
+			b.WriteString(cell)
+			if pretty && i < len(widths) {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+			b.WriteString(" |")
+		}
+		fmt.Fprintln(f, b.String())
+	}
+
+	writeRow(t.headers)
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width+2)
+	}
+	fmt.Fprintf(f, "|%s|\n", strings.Join(separator, "|"))
+	for _, row := range t.rows {
+		writeRow(row)
+	}
+}
+
+type spanTreeNode struct {
+	spanInfo  spanInfo
+	children  []*spanTreeNode
+	depth     int
+	spanIndex int
+	async     bool // ends after its parent, e.g. fire-and-forget work
+	cyclic    bool // a child's parent chain loops back to this span or an ancestor
+	truncated bool // children left out because the tree reached maxSpanTreeDepth
+
+	// detachedCycle, set on the root, lists the spans left out of the tree
+	// because their parent chain loops without reaching the root
+	detachedCycle []int
+}
+
+// spanKey returns the key linking a span into the tree. Spans with an empty
+// span ID get a synthetic key so they don't all collapse into one node.
+func spanKey(si spanInfo, index int) string {
+	if si.span.SpanID().IsEmpty() {
+		return fmt.Sprintf("empty-span-id-%d", index)
+	}
+	return si.span.SpanID().String()
+}
+
+func buildSpanTree(ti *traceInfo) *spanTreeNode {
+	// Create a map of span key to spanInfo for quick lookup
+	spanMap := make(map[string]spanInfo)
+	spanIndexMap := make(map[string]int)
+	rootKey := ""
+	rootSpan, _ := ti.findRootSpan()
+	for i, si := range ti.spans {
+		key := spanKey(si, i)
+		spanMap[key] = si
+		spanIndexMap[key] = i + 1 // 1-indexed for display
+		if rootKey == "" && si.span == rootSpan.span {
+			rootKey = key
+		}
+	}
+
+	root := &spanTreeNode{
+		spanInfo:  rootSpan,
+		children:  []*spanTreeNode{},
+		depth:     0,
+		spanIndex: spanIndexMap[rootKey],
+	}
+	if rootKey == "" {
+		return root
+	}
+
+	// Index children by parent ID once, then build the tree with an explicit
+	// stack so a pathologically deep trace can't overflow the goroutine stack
+	childKeys := make(map[string][]string)
+	for key, si := range spanMap {
+		parentID := si.span.ParentSpanID().String()
+		childKeys[parentID] = append(childKeys[parentID], key)
+	}
+
+	visited := map[string]bool{rootKey: true}
+	stack := []*spanTreeNode{root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		attachChildren(node, childKeys, spanMap, spanIndexMap, visited)
+		stack = append(stack, node.children...)
+	}
+	root.detachedCycle = detachedCycleSpans(spanMap, spanIndexMap, visited)
+	return root
+}
+
+// detachedCycleSpans returns, in span order, the spans never reached from the
+// root whose parent chain loops (A's parent is B, B's parent is A). Cycles
+// through the root are flagged by attachChildren instead.
+func detachedCycleSpans(spanMap map[string]spanInfo, spanIndexMap map[string]int, visited map[string]bool) []int {
+	// Every span on a walked chain shares its outcome, so each span is
+	// walked once even for long orphaned chains
+	loops := make(map[string]bool)
+	for key := range spanMap {
+		if _, done := loops[key]; done || visited[key] {
+			continue
+		}
+		var path []string
+		onPath := make(map[string]bool)
+		cyclic := false
+		for current := key; ; {
+			if result, done := loops[current]; done {
+				cyclic = result
+				break
+			}
+			if onPath[current] {
+				cyclic = true
+				break
+			}
+			onPath[current] = true
+			path = append(path, current)
+
+			parent := spanMap[current].span.ParentSpanID()
+			if parent.IsEmpty() {
+				break
+			}
+			parentKey := parent.String()
+			if _, ok := spanMap[parentKey]; !ok || visited[parentKey] {
+				break
+			}
+			current = parentKey
+		}
+		for _, k := range path {
+			loops[k] = cyclic
+		}
+	}
+
+	var numbers []int
+	for key, cyclic := range loops {
+		if cyclic {
+			numbers = append(numbers, spanIndexMap[key])
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// maxCycleSpansListed caps the span numbers named in a cyclic parent note
+const maxCycleSpansListed = 5
+
+// detachedCycleNote returns the warning shown under a timeline that left out
+// spans with cyclic parent references
+func detachedCycleNote(numbers []int) string {
+	var spans []string
+	for i, n := range numbers {
+		if i == maxCycleSpansListed {
+			spans = append(spans, fmt.Sprintf("%d more", len(numbers)-i))
+			break
+		}
+		spans = append(spans, fmt.Sprintf("#%d", n))
+	}
+	return fmt.Sprintf("⚠️ cyclic parent reference: %d span(s) (%s) have parent chains that loop without reaching the root and are not shown in the timeline",
+		len(numbers), strings.Join(spans, ", "))
+}
+
+// maxSpanTreeDepth caps how deep the span tree is built; spans below it are
+// left out and their ancestor at the limit is flagged as truncated
+const maxSpanTreeDepth = 500
+
+// attachChildren adds the spans whose parent is node. Spans already in the
+// tree are skipped and node is flagged instead, so malformed data with cyclic
+// parent references (A's parent is B, B's parent is A) can't loop forever.
+func attachChildren(node *spanTreeNode, childKeys map[string][]string, spanMap map[string]spanInfo, spanIndexMap map[string]int, visited map[string]bool) {
+	// A span without an ID can't be referenced as a parent; matching on the
+	// empty string would adopt every parentless span, including the root
+	if node.spanInfo.span.SpanID().IsEmpty() {
+		return
+	}
+
+	for _, key := range childKeys[node.spanInfo.span.SpanID().String()] {
+		if visited[key] {
+			node.cyclic = true
+			continue
+		}
+		if node.depth >= maxSpanTreeDepth {
+			node.truncated = true
+			continue
+		}
+		visited[key] = true
+		si := spanMap[key]
+		node.children = append(node.children, &spanTreeNode{
+			spanInfo:  si,
+			children:  []*spanTreeNode{},
+			depth:     node.depth + 1,
+			spanIndex: spanIndexMap[key],
+			async:     si.span.EndTimestamp() > node.spanInfo.span.EndTimestamp(),
+		})
+	}
+
+	// Sort children by start time
+	sort.Slice(node.children, func(i, j int) bool {
+		return spanBefore(node.children[i].spanInfo.span, node.children[j].spanInfo.span)
+	})
+}
+
+// hideSpanKinds removes spans whose kind isn't shown from the tree, moving
+// their children up to the nearest shown ancestor. The root always stays as
+// the anchor of the timeline.
+func hideSpanKinds(node *spanTreeNode, config *Config) {
+	var children []*spanTreeNode
+	for _, child := range node.children {
+		hideSpanKinds(child, config)
+		if config.showSpanKind(child.spanInfo.span.Kind()) {
+			children = append(children, child)
+		} else {
+			children = append(children, child.children...)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return spanBefore(children[i].spanInfo.span, children[j].spanInfo.span)
+	})
+	for _, child := range children {
+		// Promoted spans are compared against their new parent
+		child.async = child.spanInfo.span.EndTimestamp() > node.spanInfo.span.EndTimestamp()
+	}
+	node.children = children
+	setTreeDepth(node, node.depth)
+}
+
+func setTreeDepth(node *spanTreeNode, depth int) {
+	node.depth = depth
+	for _, child := range node.children {
+		setTreeDepth(child, depth+1)
+	}
+}
+
+// visibleSpans returns the spans whose kind is shown, with their 1-based
+// position in the trace so numbering matches the timeline
+func visibleSpans(ti *traceInfo, config *Config) ([]spanInfo, []int) {
+	var spans []spanInfo
+	var numbers []int
+	for i, si := range ti.spans {
+		if config.showSpanKind(si.span.Kind()) {
+			spans = append(spans, si)
+			numbers = append(numbers, i+1)
+		}
+	}
+	return spans, numbers
+}
+
+// asyncMarker flags timeline spans that outlive their parent
+const asyncMarker = "⤳ async"
+
+// asyncLegend explains asyncMarker below timelines that use it
+const asyncLegend = "⤳ async: the span ends after its parent, so it ran asynchronously (fire-and-forget) and its duration doesn't count towards the parent's. Unmarked child spans finish within their parent."
+
+// hasAsyncSpans reports whether any span in the tree outlives its parent
+func hasAsyncSpans(node *spanTreeNode) bool {
+	if node.async {
+		return true
+	}
+	for _, child := range node.children {
+		if hasAsyncSpans(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// timelineLayout sets the widths of the span name column and the duration
+// bar in the ASCII timeline
+type timelineLayout struct {
+	nameWidth int
+	barWidth  int
+	logScale  bool // scale bars logarithmically, see barFraction
+	precision int  // decimal places for durations, see formatDuration
+	waterfall bool // list spans in start order with offset bars, see writeSpanWaterfall
+
+	// traceStart is where waterfall bars are offset from
+	traceStart pcommon.Timestamp
+
+	// eventMarkers maps event names to the marker drawn in the bar at the
+	// event's time; nil when -timeline-events is off
+	eventMarkers map[string]rune
 
-	// Create anchor link (markdown anchors are lowercase, strip special chars, replace spaces with hyphens)
-	// Header is: "## Trace 1: `abc123`" which becomes anchor: "trace-1-abc123"
-	anchor := fmt.Sprintf("trace-%d-%s", traceNum, strings.ToLower(ti.traceID))
+	// downstreams maps span numbers of CLIENT spans calling an
+	// uninstrumented service to the address they called
+	downstreams map[int]string
 
-	fmt.Fprintf(f, "| [#%d](#%s) | %s | %v | %d | %s | %s |\n",
-		traceNum, anchor, serviceName, duration, len(ti.spans), rootSpan, status)
+	// config is consulted for -critical-span-pattern error markers
+	config *Config
 }
 
-type spanTreeNode struct {
-	spanInfo  spanInfo
-	children  []*spanTreeNode
-	depth     int
-	spanIndex int
+// defaultTimelineLayout is used when -term-width isn't set
+var defaultTimelineLayout = timelineLayout{nameWidth: 50, barWidth: 24}
+
+// timelineLayoutFor splits -term-width between the name column and the bar.
+// The fixed parts of a line (tree connector, duration and separators) take
+// 13 characters; a third of the rest goes to the bar. Tree indentation
+// and status markers are not counted, so deep or failed spans can still run
+// past the width.
+func timelineLayoutFor(config *Config) timelineLayout {
+	layout := defaultTimelineLayout
+	if config.TermWidth > 0 {
+		available := config.TermWidth - 13
+		layout.barWidth = max(available/3, minTimelineBarWidth)
+		layout.nameWidth = max(available-layout.barWidth, minTimelineNameWidth)
+	}
+	layout.logScale = config.LogScale
+	layout.precision = config.DurationPrecision
+	layout.waterfall = config.View == "waterfall"
+	return layout
 }
 
-func buildSpanTree(ti *traceInfo) *spanTreeNode {
-	// Create a map of span ID to spanInfo for quick lookup
-	spanMap := make(map[string]spanInfo)
-	spanIndexMap := make(map[string]int)
-	for i, si := range ti.spans {
-		spanID := si.span.SpanID().String()
-		spanMap[spanID] = si
-		spanIndexMap[spanID] = i + 1 // 1-indexed for display
+// barFraction returns how much of the full bar width a span of duration
+// fills. On a log scale durations are measured in decades above 1µs, so a
+// 1ms span in a 10s trace still fills 3/7 of the bar instead of nothing.
+func barFraction(duration, traceDuration time.Duration, logScale bool) float64 {
+	if !logScale {
+		return float64(duration) / float64(traceDuration)
 	}
+	const floor = float64(time.Microsecond)
+	total := math.Log10(max(float64(traceDuration), floor) / floor)
+	if total == 0 {
+		return 1
+	}
+	return math.Log10(max(float64(duration), floor)/floor) / total
+}
+
+// Narrowest name column and bar -term-width can produce
+const (
+	minTimelineNameWidth = 20
+	minTimelineBarWidth  = 8
+)
+
+// timelineEventMarkers are assigned to event names in order of first
+// appearance; names beyond the list share otherEventMarker
+var timelineEventMarkers = []rune("◆◇●○▲△■□")
+
+const otherEventMarker = '•'
 
-	// Find root span (no parent)
-	var rootSpan spanInfo
+// assignEventMarkers gives each distinct event name in the trace a marker
+func assignEventMarkers(ti *traceInfo) map[string]rune {
+	markers := make(map[string]rune)
 	for _, si := range ti.spans {
-		if si.span.ParentSpanID().IsEmpty() {
-			rootSpan = si
-			break
+		events := si.span.Events()
+		for i := 0; i < events.Len(); i++ {
+			name := events.At(i).Name()
+			if _, ok := markers[name]; ok {
+				continue
+			}
+			if len(markers) < len(timelineEventMarkers) {
+				markers[name] = timelineEventMarkers[len(markers)]
+			} else {
+				markers[name] = otherEventMarker
+			}
 		}
 	}
+	return markers
+}
 
-	// If no root found, use first span
-	if rootSpan.span.SpanID().IsEmpty() && len(ti.spans) > 0 {
-		rootSpan = ti.spans[0]
+// overlayEventMarkers draws a span's duration bar with each event's marker at
+// its position relative to the span's start and end
+func overlayEventMarkers(span ptrace.Span, barLength int, markers map[string]rune) string {
+	bar := []rune(strings.Repeat("█", barLength))
+	start, end := span.StartTimestamp(), span.EndTimestamp()
+	events := span.Events()
+	for i := 0; i < events.Len(); i++ {
+		event := events.At(i)
+		pos := 0
+		if end > start {
+			offset := min(max(event.Timestamp(), start), end) - start
+			pos = min(int(float64(offset)/float64(end-start)*float64(barLength)), barLength-1)
+		}
+		bar[pos] = markers[event.Name()]
 	}
+	return string(bar)
+}
 
-	// Build tree recursively
-	root := &spanTreeNode{
-		spanInfo:  rootSpan,
-		children:  []*spanTreeNode{},
-		depth:     0,
-		spanIndex: spanIndexMap[rootSpan.span.SpanID().String()],
+// eventLegend lists the markers used in a trace's timeline, e.g.
+// "◆ exception, ◇ retry"
+func eventLegend(markers map[string]rune) string {
+	byMarker := make(map[rune]string)
+	var others []string
+	for name, marker := range markers {
+		if marker == otherEventMarker {
+			others = append(others, name)
+		} else {
+			byMarker[marker] = name
+		}
 	}
 
-	buildChildren(root, spanMap, spanIndexMap)
-	return root
+	var entries []string
+	for _, marker := range timelineEventMarkers {
+		if name, ok := byMarker[marker]; ok {
+			entries = append(entries, fmt.Sprintf("%c %s", marker, name))
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		entries = append(entries, fmt.Sprintf("%c other (%s)", otherEventMarker, strings.Join(others, ", ")))
+	}
+	return strings.Join(entries, ", ")
 }
 
-func buildChildren(node *spanTreeNode, spanMap map[string]spanInfo, spanIndexMap map[string]int) {
-	parentID := node.spanInfo.span.SpanID().String()
-
-	for _, si := range spanMap {
-		if si.span.ParentSpanID().String() == parentID {
-			child := &spanTreeNode{
-				spanInfo:  si,
-				children:  []*spanTreeNode{},
-				depth:     node.depth + 1,
-				spanIndex: spanIndexMap[si.span.SpanID().String()],
-			}
-			node.children = append(node.children, child)
-			buildChildren(child, spanMap, spanIndexMap)
-		}
+// traceTimelineLayout returns the timeline layout for one trace, with event
+// markers assigned when -timeline-events is set
+func traceTimelineLayout(ti *traceInfo, config *Config) timelineLayout {
+	layout := timelineLayoutFor(config)
+	if config.TimelineEvents {
+		layout.eventMarkers = assignEventMarkers(ti)
 	}
+	layout.downstreams = uninstrumentedDownstreams(ti, config)
+	layout.traceStart = pcommon.Timestamp(ti.getEarliestTime())
+	layout.config = config
+	return layout
+}
 
-	// Sort children by start time
-	sort.Slice(node.children, func(i, j int) bool {
-		return node.children[i].spanInfo.span.StartTimestamp() < node.children[j].spanInfo.span.StartTimestamp()
-	})
+// writeTimeline writes the span timeline in the -view layout
+func writeTimeline(f io.Writer, tree *spanTreeNode, traceDuration time.Duration, layout timelineLayout) {
+	if layout.waterfall {
+		writeSpanWaterfall(f, tree, traceDuration, layout)
+		return
+	}
+	writeSpanTree(f, tree, traceDuration, layout, "", true)
 }
 
-func writeSpanTree(f *os.File, node *spanTreeNode, traceDuration time.Duration, prefix string, isLast bool) {
+func writeSpanTree(f io.Writer, node *spanTreeNode, traceDuration time.Duration, layout timelineLayout, prefix string, isLast bool) {
 	span := node.spanInfo.span
 	duration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
 
-	// Calculate duration bar (max 24 chars)
-	barLength := 24
+	// Calculate duration bar (at most layout.barWidth chars)
+	barLength := layout.barWidth
 	if traceDuration > 0 {
-		barLength = int(float64(duration) / float64(traceDuration) * 24)
-		if barLength < 1 {
-			barLength = 1
-		}
-		if barLength > 24 {
-			barLength = 24
-		}
+		barLength = int(barFraction(duration, traceDuration, layout.logScale) * float64(layout.barWidth))
 	}
-
-	bar := strings.Repeat("█", barLength)
+	bar := spanBar(span, barLength, layout)
 
 	// Format duration with proper width
-	durationStr := fmt.Sprintf("[%6s]", formatDuration(duration))
-
-	// Add error indicator if needed
-	statusIndicator := ""
-	if span.Status().Code() == ptrace.StatusCodeError {
-		statusIndicator = " ⚠️ ERROR"
-	}
+	durationStr := fmt.Sprintf("[%6s]", formatDuration(duration, layout.precision))
 
 	// Determine tree characters
 	connector := "├─"
@@ -338,17 +1251,7 @@ func writeSpanTree(f *os.File, node *spanTreeNode, traceDuration time.Duration,
 		connector = ""
 	}
 
-	// Calculate padding to align duration and bars
-	nameMaxLen := 45 // Reduced to account for span number
-	name := span.Name()
-	if len(name) > nameMaxLen {
-		name = name[:nameMaxLen-3] + "..."
-	}
-
-	// Add span number prefix
-	nameWithNumber := fmt.Sprintf("[#%d] %s", node.spanIndex, name)
-
-	fmt.Fprintf(f, "%s%s %-50s %s %s%s\n", prefix, connector, nameWithNumber, durationStr, bar, statusIndicator)
+	fmt.Fprintf(f, "%s%s %-*s %s %s%s\n", prefix, connector, layout.nameWidth, spanLabel(node, layout), durationStr, bar, spanStatusIndicator(node, layout))
 
 	// Write children
 	for i, child := range node.children {
@@ -361,28 +1264,83 @@ func writeSpanTree(f *os.File, node *spanTreeNode, traceDuration time.Duration,
 				childPrefix += "│  "
 			}
 		}
-		writeSpanTree(f, child, traceDuration, childPrefix, childIsLast)
+		writeSpanTree(f, child, traceDuration, layout, childPrefix, childIsLast)
+	}
+}
+
+// spanBar draws a span's duration bar, clamped to between 1 and
+// layout.barWidth characters
+func spanBar(span ptrace.Span, barLength int, layout timelineLayout) string {
+	barLength = min(max(barLength, 1), layout.barWidth)
+	if layout.eventMarkers != nil {
+		return overlayEventMarkers(span, barLength, layout.eventMarkers)
+	}
+	return strings.Repeat("█", barLength)
+}
+
+// spanLabel returns "[#n] name", with the name shortened to fit the name
+// column
+func spanLabel(node *spanTreeNode, layout timelineLayout) string {
+	nameMaxLen := layout.nameWidth - 5 // Reduced to account for span number
+	name := node.spanInfo.span.Name()
+	if len(name) > nameMaxLen {
+		name = name[:nameMaxLen-3] + "..."
+	}
+	return fmt.Sprintf("[#%d] %s", node.spanIndex, name)
+}
+
+// spanStatusIndicator returns the markers written after a span's bar
+func spanStatusIndicator(node *spanTreeNode, layout timelineLayout) string {
+	span := node.spanInfo.span
+	statusIndicator := ""
+	if span.Status().Code() == ptrace.StatusCodeError {
+		statusIndicator = " ⚠️ ERROR"
+		if layout.config != nil && layout.config.isCriticalError(span) {
+			statusIndicator = " " + criticalMarker + " CRITICAL ERROR"
+		}
+	}
+	if span.SpanID().IsEmpty() {
+		statusIndicator += " ⚠️ NO SPAN ID"
+	}
+	if node.async {
+		statusIndicator += " " + asyncMarker
 	}
+	if node.cyclic {
+		statusIndicator += " ⚠️ cyclic parent reference"
+	}
+	if target, ok := layout.downstreams[node.spanIndex]; ok {
+		statusIndicator += fmt.Sprintf(" %s: %s", uninstrumentedMarker, target)
+	}
+	if node.truncated {
+		statusIndicator += fmt.Sprintf(" ⚠️ deeper spans not shown (depth limit %d)", maxSpanTreeDepth)
+	}
+	return statusIndicator
 }
 
-func formatDuration(d time.Duration) string {
+// formatDuration renders d in its largest fitting unit with precision
+// decimal places; a negative precision uses one for µs and ms and two for s
+func formatDuration(d time.Duration, precision int) string {
+	fixed := func(value float64, unit string, auto int) string {
+		if precision < 0 {
+			precision = auto
+		}
+		return fmt.Sprintf("%.*f%s", precision, value, unit)
+	}
 	if d < time.Microsecond {
 		return fmt.Sprintf("%dns", d.Nanoseconds())
 	} else if d < time.Millisecond {
-		return fmt.Sprintf("%.1fµs", float64(d.Nanoseconds())/1000)
+		return fixed(float64(d.Nanoseconds())/1000, "µs", 1)
 	} else if d < time.Second {
-		return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+		return fixed(float64(d.Nanoseconds())/1e6, "ms", 1)
 	}
-	return fmt.Sprintf("%.2fs", d.Seconds())
+	return fixed(d.Seconds(), "s", 2)
 }
 
-func writeTrace(f *os.File, index int, ti *traceInfo) {
-	fmt.Fprintf(f, "## Trace %d: %s\n\n", index, ti.traceID)
-
-	// Sort spans by start time for processing
-	sort.Slice(ti.spans, func(i, j int) bool {
-		return ti.spans[i].span.StartTimestamp() < ti.spans[j].span.StartTimestamp()
-	})
+func writeTrace(f io.Writer, index int, ti *traceInfo, config *Config) {
+	writeTraceStart(f, index, ti, config)
+	if ti.displayName(config) != ti.traceID {
+		fmt.Fprintf(f, "*Trace ID: `%s`*\n\n", ti.traceID)
+	}
 
 	// Calculate trace duration and status
 	duration := ti.getDuration()
@@ -391,108 +1349,298 @@ func writeTrace(f *os.File, index int, ti *traceInfo) {
 		status = "⚠️ ERROR"
 	}
 
-	fmt.Fprintf(f, "**Duration:** %v | **Spans:** %d | **Status:** %s\n\n", duration, len(ti.spans), status)
-
-	// Write service info table
-	fmt.Fprintf(f, "### Service Info\n")
-	fmt.Fprintf(f, "| Property | Value |\n")
-	fmt.Fprintf(f, "|----------|-------|\n")
+	fmt.Fprintf(f, "**Duration:** %v | **Spans:** %d | **Status:** %s", duration, len(ti.spans), status)
+	if ti.batches > 1 {
+		fmt.Fprintf(f, " | **Received:** %d batches over %v", ti.batches, ti.lastArrival.Sub(ti.firstArrival).Round(time.Millisecond))
+	}
+	fmt.Fprintf(f, "\n\n")
 
-	if len(ti.spans) > 0 {
-		resource := ti.spans[0].resource
-		if serviceName, ok := resource.Attributes().Get("service.name"); ok {
-			fmt.Fprintf(f, "| Service | %s |\n", serviceName.AsString())
-		}
-		if serviceVersion, ok := resource.Attributes().Get("service.version"); ok {
-			fmt.Fprintf(f, "| Version | %s |\n", serviceVersion.AsString())
-		}
-		if env, ok := resource.Attributes().Get("deployment.environment"); ok {
-			fmt.Fprintf(f, "| Environment | %s |\n", env.AsString())
+	if len(ti.spans) > 1 {
+		if cp, ok := computeCriticalPath(ti); ok {
+			fmt.Fprintf(f, "**Critical Path:** %v of %v total (%s) | **Parallelism:** %.1fx\n\n",
+				cp.length, duration, formatSpanChain(cp.spanNumbers), cp.parallelism)
 		}
 	}
-	fmt.Fprintf(f, "\n")
 
-	// Write ASCII timeline
-	fmt.Fprintf(f, "### Span Timeline\n")
-	fmt.Fprintf(f, "```\n")
-	tree := buildSpanTree(ti)
-	writeSpanTree(f, tree, duration, "", true)
-	fmt.Fprintf(f, "```\n\n")
+	if ti.collisionParts > 0 {
+		fmt.Fprintf(f, "> ⚠️ Trace ID reused: this is part %s of %d unrelated requests sharing the ID, split by -split-collisions.\n\n", ti.collisionPart, ti.collisionParts)
+	}
 
-	// Write span summary table with inline collapsible details
-	fmt.Fprintf(f, "### Span Summary\n")
-	fmt.Fprintf(f, "| # | Name | Duration | Status | Kind | Details |\n")
-	fmt.Fprintf(f, "|---|------|----------|--------|------|----------|\n")
+	if missing := ti.missingRequiredSpans(config); len(missing) > 0 {
+		fmt.Fprintf(f, "> ❌ Missing required spans: %s\n\n", strings.Join(missing, ", "))
+	}
 
-	for i, si := range ti.spans {
-		span := si.span
-		spanDuration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
-		statusStr := span.Status().Code().String()
+	if skew, ok := ti.spansBeforeRoot(); ok {
+		fmt.Fprintf(f, "> ⚠️ %s\n\n", skew.note())
+	}
 
-		// Add emoji for error status
-		if span.Status().Code() == ptrace.StatusCodeError {
-			statusStr = "⚠️ " + statusStr
-		}
+	if n := ti.countEmptySpanIDs(); n > 0 {
+		fmt.Fprintf(f, "> ⚠️ %d span(s) have an empty span ID (malformed exporter); they can't be linked as parents in the timeline.\n\n", n)
+	}
 
-		kind := span.Kind().String()
+	if !config.NoTables {
+		writeServiceInfo(f, ti, config)
+		writeScopeInfo(f, ti, config)
+		writeNumericAttrStats(f, ti, config)
+	}
+
+	if !config.NoTimeline {
+		// Write ASCII timeline
+		fmt.Fprintf(f, "### Span Timeline\n")
+		fmt.Fprintf(f, "```\n")
+		tree := buildSpanTree(ti)
+		hideSpanKinds(tree, config)
+		layout := traceTimelineLayout(ti, config)
+		writeTimeline(f, tree, duration, layout)
+		fmt.Fprintf(f, "```\n\n")
+		if len(layout.eventMarkers) > 0 {
+			fmt.Fprintf(f, "*Events: %s*\n\n", eventLegend(layout.eventMarkers))
+		}
+		if hasAsyncSpans(tree) {
+			fmt.Fprintf(f, "*%s*\n\n", asyncLegend)
+		}
+		if len(tree.detachedCycle) > 0 {
+			fmt.Fprintf(f, "*%s*\n\n", detachedCycleNote(tree.detachedCycle))
+		}
+	}
 
-		// Build collapsible details inline
-		detailsHTML := buildInlineSpanDetails(i+1, si)
+	if config.Sequence {
+		writeSequenceDiagram(f, ti, config)
+	}
 
-		fmt.Fprintf(f, "| %d | %s | %v | %s | %s | %s |\n", i+1, span.Name(), spanDuration, statusStr, kind, detailsHTML)
+	if !config.NoTables {
+		writeSpanSummary(f, ti, config)
 	}
 
-	fmt.Fprintf(f, "\n---\n\n")
+	writeTraceEnd(f, config)
 }
 
-func writeTraceSummary(f *os.File, index int, ti *traceInfo, config *Config) {
-	fmt.Fprintf(f, "## Trace %d: %s\n\n", index, ti.traceID)
-
-	// Sort spans by start time for processing
-	sort.Slice(ti.spans, func(i, j int) bool {
-		return ti.spans[i].span.StartTimestamp() < ti.spans[j].span.StartTimestamp()
-	})
+// writeTraceStart writes a trace's section header. With -collapse-traces the
+// section is wrapped in a <details> element with the header as its summary,
+// keeping it a markdown header so table of contents links still resolve.
+func writeTraceStart(f io.Writer, index int, ti *traceInfo, config *Config) {
+	if config.CollapseTraces {
+		fmt.Fprintf(f, "<details>\n<summary>\n\n## %s\n\n</summary>\n\n", escapeMarkdown(traceHeading(index, ti, config)))
+		return
+	}
+	fmt.Fprintf(f, "## %s\n\n", escapeMarkdown(traceHeading(index, ti, config)))
+}
 
-	// Calculate trace duration and status
-	duration := ti.getDuration()
-	status := "✓ OK"
-	if ti.hasError() {
-		status = "⚠️ ERROR"
+// writeTraceEnd closes a trace's section
+func writeTraceEnd(f io.Writer, config *Config) {
+	if config.CollapseTraces {
+		fmt.Fprintf(f, "</details>\n\n")
 	}
+	fmt.Fprintf(f, "---\n\n")
+}
 
-	totalSpans := len(ti.spans)
-	fmt.Fprintf(f, "**Duration:** %v | **Spans:** %d | **Status:** %s\n\n", duration, totalSpans, status)
+// writeTraceFailure writes the placeholder for a trace that failed to render
+func writeTraceFailure(f io.Writer, index int, ti *traceInfo, reason string, config *Config) {
+	// Keep the heading so links from the table of contents still resolve
+	writeTraceStart(f, index, ti, config)
+	fmt.Fprintf(f, "> ⚠️ This trace could not be rendered: %s\n\n", reason)
+	writeTraceEnd(f, config)
+}
 
-	// Write service info table
+func writeServiceInfo(f io.Writer, ti *traceInfo, config *Config) {
 	fmt.Fprintf(f, "### Service Info\n")
 	fmt.Fprintf(f, "| Property | Value |\n")
 	fmt.Fprintf(f, "|----------|-------|\n")
 
 	if len(ti.spans) > 0 {
 		resource := ti.spans[0].resource
-		if serviceName, ok := resource.Attributes().Get("service.name"); ok {
-			fmt.Fprintf(f, "| Service | %s |\n", serviceName.AsString())
+		if serviceName, key := spanServiceName(resource, ti.spans[0].span, config); key != "" {
+			// Say where the name came from when service.name wasn't set
+			serviceName = escapeMarkdown(serviceName)
+			if key != "service.name" {
+				serviceName += fmt.Sprintf(" (from %s)", codeSpan(key))
+			}
+			fmt.Fprintf(f, "| Service | %s |\n", escapeTableCell(serviceName))
 		}
 		if serviceVersion, ok := resource.Attributes().Get("service.version"); ok {
-			fmt.Fprintf(f, "| Version | %s |\n", serviceVersion.AsString())
+			fmt.Fprintf(f, "| Version | %s |\n", escapeTableCell(escapeMarkdown(serviceVersion.AsString())))
 		}
 		if env, ok := resource.Attributes().Get("deployment.environment"); ok {
-			fmt.Fprintf(f, "| Environment | %s |\n", env.AsString())
+			fmt.Fprintf(f, "| Environment | %s |\n", escapeTableCell(escapeMarkdown(env.AsString())))
+		}
+	}
+	if schemas := resourceSchemaURLs(ti, config); len(schemas) == 1 {
+		fmt.Fprintf(f, "| Resource Schema URL | %s |\n", escapeTableCell(escapeMarkdown(schemas[0].url)))
+	} else if len(schemas) > 1 {
+		// Services on different semantic convention versions name
+		// attributes differently, so call the mismatch out
+		parts := make([]string, len(schemas))
+		for i, schema := range schemas {
+			parts[i] = fmt.Sprintf("%s: %s", escapeMarkdown(schema.service), escapeMarkdown(schema.url))
+		}
+		fmt.Fprintf(f, "| Resource Schema URLs | ⚠️ differ between services: %s |\n", escapeTableCell(strings.Join(parts, ", ")))
+	}
+	fmt.Fprintf(f, "\n")
+}
+
+// resourceSchema is a resource schema URL seen in a trace and the service
+// that first reported it
+type resourceSchema struct {
+	service string
+	url     string
+}
+
+// resourceSchemaURLs returns the distinct resource schema URLs in a trace,
+// in span order
+func resourceSchemaURLs(ti *traceInfo, config *Config) []resourceSchema {
+	var schemas []resourceSchema
+	seen := make(map[string]bool)
+	for _, si := range ti.spans {
+		if si.resourceSchemaURL == "" || seen[si.resourceSchemaURL] {
+			continue
+		}
+		seen[si.resourceSchemaURL] = true
+		service, _ := spanServiceName(si.resource, si.span, config)
+		schemas = append(schemas, resourceSchema{service: service, url: si.resourceSchemaURL})
+	}
+	return schemas
+}
+
+// writeScopeInfo lists the instrumentation scopes in a trace along with
+// their schema URL and attributes. It's skipped when no scope carries either,
+// since name and version alone add little.
+func writeScopeInfo(f io.Writer, ti *traceInfo, config *Config) {
+	scopes := distinctScopes(ti)
+	detailed := false
+	for _, si := range scopes {
+		if si.scopeSchemaURL != "" || si.scope.Attributes().Len() > 0 {
+			detailed = true
+			break
+		}
+	}
+	if !detailed {
+		return
+	}
+
+	fmt.Fprintf(f, "### Instrumentation Scopes\n")
+	table := newMarkdownTable("Scope", "Version", "Schema URL", "Attributes")
+	for _, si := range scopes {
+		var attrs []string
+		for _, attr := range spanAttributes(si.scope.Attributes(), config.FlattenAttrs) {
+			attrs = append(attrs, fmt.Sprintf("%s: %s", codeSpan(attr.key), formatValue(attr.value)))
+		}
+		separator := "<br>"
+		if config.DetailsMode != "html" {
+			separator = ", "
 		}
+		table.addRow(escapeMarkdown(si.scope.Name()), escapeMarkdown(si.scope.Version()), escapeMarkdown(si.scopeSchemaURL), strings.Join(attrs, separator))
 	}
+	table.write(f, config.Pretty)
 	fmt.Fprintf(f, "\n")
+}
+
+// distinctScopes returns one span per distinct instrumentation scope in the
+// trace, in order of first appearance
+func distinctScopes(ti *traceInfo) []spanInfo {
+	seen := make(map[string]bool)
+	var scopes []spanInfo
+	for _, si := range ti.spans {
+		key := si.scope.Name() + "|" + si.scope.Version() + "|" + si.scopeSchemaURL
+		if !seen[key] {
+			seen[key] = true
+			scopes = append(scopes, si)
+		}
+	}
+	return scopes
+}
+
+// spanColumnNames lists the span summary table columns selectable with
+// -columns, and spanColumnHeaders their header text
+var (
+	spanColumnNames   = []string{"num", "name", "duration", "self", "status", "kind", "start", "pct", "service", "details"}
+	spanColumnHeaders = map[string]string{
+		"num":      "#",
+		"name":     "Name",
+		"duration": "Duration",
+		"self":     "Self Time",
+		"status":   "Status",
+		"kind":     "Kind",
+		"start":    "Start",
+		"pct":      "% of Trace",
+		"service":  "Service",
+		"details":  "Details",
+	}
+)
+
+// defaultSpanColumns is the span summary table layout when -columns isn't set
+const defaultSpanColumns = "num,name,duration,pct,status,kind,details"
+
+// spanTableRow holds what the span summary columns need for one span
+type spanTableRow struct {
+	number   int
+	si       spanInfo
+	service  string
+	self     time.Duration
+	offset   time.Duration // start relative to the trace start
+	duration time.Duration
+	traceDur time.Duration
+}
+
+// spanTableRows builds the rows for the visible spans of a trace
+func spanTableRows(ti *traceInfo, config *Config) []spanTableRow {
+	spans, numbers := visibleSpans(ti, config)
+	selfTimes := spanSelfTimes(ti)
+	earliest := ti.getEarliestTime()
+	traceDuration := ti.getDuration()
+	rows := make([]spanTableRow, len(spans))
+	for i, si := range spans {
+		span := si.span
+		service, _ := spanServiceName(si.resource, span, config)
+		rows[i] = spanTableRow{
+			number:   numbers[i],
+			si:       si,
+			service:  service,
+			self:     selfTimes[numbers[i]-1],
+			offset:   time.Duration(uint64(span.StartTimestamp()) - earliest),
+			duration: time.Duration(span.EndTimestamp() - span.StartTimestamp()),
+			traceDur: traceDuration,
+		}
+	}
+	return rows
+}
 
-	// Write ASCII timeline
-	fmt.Fprintf(f, "### Span Timeline\n")
-	fmt.Fprintf(f, "```\n")
-	tree := buildSpanTree(ti)
-	writeSpanTree(f, tree, duration, "", true)
-	fmt.Fprintf(f, "```\n\n")
+// text returns the plain-text value of a column; status and details are
+// rendered by each output format itself
+func (r spanTableRow) text(column string) string {
+	span := r.si.span
+	switch column {
+	case "num":
+		return fmt.Sprintf("%d", r.number)
+	case "name":
+		return span.Name()
+	case "duration":
+		return r.duration.String()
+	case "self":
+		return r.self.String()
+	case "status":
+		return span.Status().Code().String()
+	case "kind":
+		return span.Kind().String()
+	case "start":
+		return "+" + r.offset.String()
+	case "pct":
+		return formatPercentOfTrace(r.duration, r.traceDur)
+	case "service":
+		return r.service
+	}
+	return ""
+}
+
+// writeSpanSummary writes the span table; in summary mode only the first
+// MaxSpansPerTrace spans are listed
+func writeSpanSummary(f io.Writer, ti *traceInfo, config *Config) {
+	rows := spanTableRows(ti, config)
+	totalSpans := len(rows)
 
 	// Determine how many spans to show
-	maxSpans := config.MaxSpansPerTrace
-	if maxSpans == 0 || maxSpans > totalSpans {
-		maxSpans = totalSpans
+	maxSpans := totalSpans
+	if config.SummaryMode && config.MaxSpansPerTrace > 0 && config.MaxSpansPerTrace < totalSpans {
+		maxSpans = config.MaxSpansPerTrace
 	}
 
 	// Write span summary table with inline collapsible details
@@ -501,174 +1649,344 @@ func writeTraceSummary(f *os.File, index int, ti *traceInfo, config *Config) {
 	} else {
 		fmt.Fprintf(f, "### Span Summary\n")
 	}
-	fmt.Fprintf(f, "| # | Name | Duration | Status | Kind | Details |\n")
-	fmt.Fprintf(f, "|---|------|----------|--------|------|----------|\n")
-
-	for i := 0; i < maxSpans; i++ {
-		si := ti.spans[i]
-		span := si.span
-		spanDuration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
-		statusStr := span.Status().Code().String()
-
-		// Add emoji for error status
-		if span.Status().Code() == ptrace.StatusCodeError {
-			statusStr = "⚠️ " + statusStr
+	columns := config.spanColumns
+	if config.DetailsMode == "none" {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(column string) bool { return column == "details" })
+	}
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = spanColumnHeaders[column]
+	}
+	table := newMarkdownTable(headers...)
+
+	for _, row := range rows[:maxSpans] {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			switch column {
+			case "status":
+				// Add emoji for error status
+				cells[i] = row.text(column)
+				if row.si.span.Status().Code() == ptrace.StatusCodeError {
+					cells[i] = config.errorMarker(row.si.span) + " " + cells[i]
+				}
+			case "details":
+				// Build collapsible details inline
+				cells[i] = buildInlineSpanDetails(row.number, row.si, config)
+			default:
+				cells[i] = escapeMarkdown(row.text(column))
+			}
 		}
-
-		kind := span.Kind().String()
-
-		// Build collapsible details inline
-		detailsHTML := buildInlineSpanDetails(i+1, si)
-
-		fmt.Fprintf(f, "| %d | %s | %v | %s | %s | %s |\n", i+1, span.Name(), spanDuration, statusStr, kind, detailsHTML)
+		table.addRow(cells...)
 	}
+	table.write(f, config.Pretty)
 
 	if maxSpans < totalSpans {
 		fmt.Fprintf(f, "\n*... %d more spans not shown*\n", totalSpans-maxSpans)
 	}
+	if hidden := len(ti.spans) - totalSpans; hidden > 0 {
+		fmt.Fprintf(f, "\n*%d spans of other kinds hidden by -span-kinds*\n", hidden)
+	}
+	fmt.Fprintf(f, "\n")
 
-	fmt.Fprintf(f, "\n---\n\n")
+	if config.DetailsMode == "plain" && slices.Contains(columns, "details") {
+		writePlainSpanDetails(f, rows[:maxSpans], config)
+	}
+	writeMapSliceAttributes(f, rows[:maxSpans], config)
 }
 
-func buildInlineSpanDetails(index int, si spanInfo) string {
-	span := si.span
-	var parts []string
+// writeMapSliceAttributes renders span attributes that are arrays of
+// objects (e.g. db.operations from batch instrumentation) as tables, one row
+// per element and one column per key
+func writeMapSliceAttributes(f io.Writer, rows []spanTableRow, config *Config) {
+	heading := false
+	for _, row := range rows {
+		for _, attr := range spanAttributes(row.si.span.Attributes(), config.FlattenAttrs) {
+			if !isMapSlice(attr.value) {
+				continue
+			}
+			if !heading {
+				fmt.Fprintf(f, "### Attribute Tables\n")
+				heading = true
+			}
+			fmt.Fprintf(f, "**#%d %s** %s\n\n", row.number, escapeMarkdown(row.si.span.Name()), codeSpan(attr.key))
+			mapSliceTable(attr.value.Slice()).write(f, config.Pretty)
+			fmt.Fprintf(f, "\n")
+		}
+	}
+}
+
+// isMapSlice reports whether val is a non-empty array whose elements are
+// all maps
+func isMapSlice(val pcommon.Value) bool {
+	if val.Type() != pcommon.ValueTypeSlice || val.Slice().Len() == 0 {
+		return false
+	}
+	slice := val.Slice()
+	for i := 0; i < slice.Len(); i++ {
+		if slice.At(i).Type() != pcommon.ValueTypeMap {
+			return false
+		}
+	}
+	return true
+}
 
-	// Show all attributes
-	if span.Attributes().Len() > 0 {
-		keys := make([]string, 0, span.Attributes().Len())
-		span.Attributes().Range(func(k string, v pcommon.Value) bool {
-			keys = append(keys, k)
+// mapSliceTable builds a table from an array of maps, with columns from the
+// union of their keys in order of first appearance
+func mapSliceTable(slice pcommon.Slice) *markdownTable {
+	var columns []string
+	index := make(map[string]int)
+	for i := 0; i < slice.Len(); i++ {
+		slice.At(i).Map().Range(func(k string, _ pcommon.Value) bool {
+			if _, ok := index[k]; !ok {
+				index[k] = len(columns)
+				columns = append(columns, k)
+			}
 			return true
 		})
-		sort.Strings(keys)
-
-		for _, key := range keys {
-			val, _ := span.Attributes().Get(key)
-			valStr := formatValue(val)
-			parts = append(parts, fmt.Sprintf("• `%s`: %s", key, valStr))
-		}
 	}
 
-	// Show events count if any
-	if span.Events().Len() > 0 {
-		parts = append(parts, fmt.Sprintf("• _Events: %d_", span.Events().Len()))
+	table := newMarkdownTable(columns...)
+	for i := 0; i < slice.Len(); i++ {
+		cells := make([]string, len(columns))
+		slice.At(i).Map().Range(func(k string, v pcommon.Value) bool {
+			cells[index[k]] = formatValue(v)
+			return true
+		})
+		table.addRow(cells...)
 	}
+	return table
+}
 
-	// Show links count if any
-	if span.Links().Len() > 0 {
-		parts = append(parts, fmt.Sprintf("• _Links: %d_", span.Links().Len()))
+// formatPercentOfTrace renders a span's share of the whole trace duration
+func formatPercentOfTrace(spanDuration, traceDuration time.Duration) string {
+	if traceDuration <= 0 {
+		return "-"
 	}
+	return fmt.Sprintf("%.1f%%", float64(spanDuration)/float64(traceDuration)*100)
+}
 
-	if len(parts) == 0 {
+func buildInlineSpanDetails(index int, si spanInfo, config *Config) string {
+	// Lead with counts so rich spans stand out when scanning the table
+	badges := detailBadges(si.span)
+	lines := spanDetailLines(si, config)
+	if badges == "" && len(lines) == 0 {
 		return "_no additional data_"
 	}
+	if config.DetailsMode == "plain" {
+		// The lines follow the table as a list, see writePlainSpanDetails
+		if badges == "" {
+			return "_see below_"
+		}
+		return "**" + badges + "**"
+	}
+	if badges != "" {
+		lines = append([]string{"**" + badges + "**"}, lines...)
+	}
+	return strings.Join(lines, "<br>")
+}
 
-	return strings.Join(parts, "<br>")
+// writePlainSpanDetails lists the span details below the span table for
+// -details-mode plain, as nested markdown bullets instead of HTML in the cells
+func writePlainSpanDetails(f io.Writer, rows []spanTableRow, config *Config) {
+	heading := false
+	for _, row := range rows {
+		lines := spanDetailLines(row.si, config)
+		if len(lines) == 0 {
+			continue
+		}
+		if !heading {
+			fmt.Fprintf(f, "### Span Details\n\n")
+			heading = true
+		}
+		fmt.Fprintf(f, "- **#%d %s**\n", row.number, escapeMarkdown(row.si.span.Name()))
+		for _, line := range lines {
+			fmt.Fprintf(f, "  - %s\n", strings.TrimPrefix(line, "• "))
+		}
+	}
+	if heading {
+		fmt.Fprintf(f, "\n")
+	}
 }
 
-func writeSpanDetailed(f *os.File, index int, si spanInfo) {
+// spanDetailLines returns the lines of a span's details cell after the
+// badges: SDK and semantic convention warnings, timestamps, attributes,
+// events and trace state
+func spanDetailLines(si spanInfo, config *Config) []string {
 	span := si.span
+	traceState := parseTraceState(span.TraceState().AsRaw())
+	dropped := sdkDroppedNote(span)
+	semconv := semconvNote(span, config)
 
-	fmt.Fprintf(f, "### Span %d: %s\n", index, span.Name())
-	fmt.Fprintf(f, "| Property | Value |\n")
-	fmt.Fprintf(f, "|----------|-------|\n")
-	fmt.Fprintf(f, "| Span ID | `%s` |\n", span.SpanID().String())
-	fmt.Fprintf(f, "| Parent ID | `%s` |\n", span.ParentSpanID().String())
-	fmt.Fprintf(f, "| Kind | %s |\n", span.Kind().String())
+	var parts []string
+	if dropped != "" {
+		parts = append(parts, dropped)
+	}
+	if semconv != "" {
+		parts = append(parts, semconv)
+	}
+	if config.SpanTimestamps {
+		parts = append(parts, fmt.Sprintf("• start `%s`", formatSpanTimestamp(span.StartTimestamp(), config)),
+			fmt.Sprintf("• end `%s`", formatSpanTimestamp(span.EndTimestamp(), config)))
+	}
 
-	duration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
-	fmt.Fprintf(f, "| Duration | %v |\n", duration)
-	fmt.Fprintf(f, "| Status | %s |\n", span.Status().Code().String())
+	// Show all attributes; arrays of objects get their own table after the
+	// span summary since a table can't nest inside a cell
+	for _, attr := range spanAttributes(span.Attributes(), config.FlattenAttrs) {
+		if isMapSlice(attr.value) {
+			parts = append(parts, fmt.Sprintf("• %s: _%d rows, see table below_", codeSpan(attr.key), attr.value.Slice().Len()))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("• %s: %s", codeSpan(attr.key), formatValue(attr.value)))
+	}
 
-	if span.Status().Message() != "" {
-		fmt.Fprintf(f, "| Status Message | %s |\n", span.Status().Message())
+	// Then events, relative to the span start
+	events, hidden := limitEvents(span.Events(), config.MaxEventsPerSpan)
+	for _, event := range events {
+		offset := time.Duration(event.Timestamp() - span.StartTimestamp())
+		if event.Timestamp() < span.StartTimestamp() {
+			offset = 0
+		}
+		parts = append(parts, fmt.Sprintf("• event %s at +%v", codeSpan(event.Name()), offset))
+	}
+	if hidden > 0 {
+		parts = append(parts, fmt.Sprintf("_… %d more events_", hidden))
 	}
-	fmt.Fprintf(f, "\n")
 
-	// Span attributes in table
-	if span.Attributes().Len() > 0 {
-		fmt.Fprintf(f, "**Key Attributes**\n")
-		fmt.Fprintf(f, "| Attribute | Value |\n")
-		fmt.Fprintf(f, "|-----------|-------|\n")
-		writeAttributesTable(f, span.Attributes())
-		fmt.Fprintf(f, "\n")
+	// Vendor sampling and routing state propagated with the span
+	for _, member := range traceState {
+		parts = append(parts, fmt.Sprintf("• tracestate %s: %s", codeSpan(member.key), codeSpan(member.value)))
+	}
+	return parts
+}
+
+// sdkDroppedNote warns that the sending SDK discarded some of a span's data
+// because of its limits, e.g. "⚠️ SDK dropped 3 attributes, 1 event (...)",
+// or returns "" when nothing was dropped
+func sdkDroppedNote(span ptrace.Span) string {
+	var dropped []string
+	for _, count := range []struct {
+		n                uint32
+		singular, plural string
+	}{
+		{span.DroppedAttributesCount(), "attribute", "attributes"},
+		{span.DroppedEventsCount(), "event", "events"},
+		{span.DroppedLinksCount(), "link", "links"},
+	} {
+		switch {
+		case count.n == 1:
+			dropped = append(dropped, "1 "+count.singular)
+		case count.n > 1:
+			dropped = append(dropped, fmt.Sprintf("%d %s", count.n, count.plural))
+		}
+	}
+	if len(dropped) == 0 {
+		return ""
 	}
+	return "⚠️ SDK dropped " + strings.Join(dropped, ", ") + " (sender-side limits; the data never reached tracedown)"
+}
 
-	// Events in table
-	if span.Events().Len() > 0 {
-		fmt.Fprintf(f, "**Events**\n")
-		fmt.Fprintf(f, "| Time | Event | Details |\n")
-		fmt.Fprintf(f, "|------|-------|----------|\n")
-		for i := 0; i < span.Events().Len(); i++ {
-			event := span.Events().At(i)
-			eventTime := time.Unix(0, int64(event.Timestamp()))
-			details := "-"
-			if event.Attributes().Len() > 0 {
-				// Get first attribute as preview
-				var firstAttr string
-				event.Attributes().Range(func(k string, v pcommon.Value) bool {
-					firstAttr = fmt.Sprintf("`%s: %s`", k, formatValue(v))
-					return false // stop after first
-				})
-				if event.Attributes().Len() > 1 {
-					details = fmt.Sprintf("%s, ...", firstAttr)
-				} else {
-					details = firstAttr
-				}
+// detailBadges summarizes how much data a span carries, e.g.
+// "3 attrs, 2 events, 1 link", or "" when it has none
+func detailBadges(span ptrace.Span) string {
+	var badges []string
+	for _, count := range []struct {
+		n                int
+		singular, plural string
+	}{
+		{span.Attributes().Len(), "attr", "attrs"},
+		{span.Events().Len(), "event", "events"},
+		{span.Links().Len(), "link", "links"},
+	} {
+		switch {
+		case count.n == 1:
+			badges = append(badges, "1 "+count.singular)
+		case count.n > 1:
+			badges = append(badges, fmt.Sprintf("%d %s", count.n, count.plural))
+		}
+	}
+	return strings.Join(badges, ", ")
+}
+
+// limitEvents returns at most limit of a span's events (0 = all) in their
+// original order, plus how many were left out. Exception events are kept
+// ahead of any others so truncation never hides an error.
+func limitEvents(events ptrace.SpanEventSlice, limit int) ([]ptrace.SpanEvent, int) {
+	if limit <= 0 || events.Len() <= limit {
+		limit = events.Len()
+	}
+
+	keep := make([]bool, events.Len())
+	kept := 0
+	for _, exceptions := range []bool{true, false} {
+		for i := 0; i < events.Len() && kept < limit; i++ {
+			if !keep[i] && (events.At(i).Name() == "exception") == exceptions {
+				keep[i] = true
+				kept++
 			}
-			fmt.Fprintf(f, "| %s | %s | %s |\n", eventTime.Format("15:04:05.000"), event.Name(), details)
 		}
-		fmt.Fprintf(f, "\n")
 	}
 
-	// Links
-	if span.Links().Len() > 0 {
-		fmt.Fprintf(f, "**Links**\n")
-		fmt.Fprintf(f, "| Trace ID | Span ID |\n")
-		fmt.Fprintf(f, "|----------|----------|\n")
-		for i := 0; i < span.Links().Len(); i++ {
-			link := span.Links().At(i)
-			fmt.Fprintf(f, "| `%s` | `%s` |\n", link.TraceID().String(), link.SpanID().String())
+	selected := make([]ptrace.SpanEvent, 0, kept)
+	for i := 0; i < events.Len(); i++ {
+		if keep[i] {
+			selected = append(selected, events.At(i))
 		}
-		fmt.Fprintf(f, "\n")
 	}
+	return selected, events.Len() - kept
 }
 
-func writeAttributes(f *os.File, attrs pcommon.Map) {
-	// Sort attributes by key for consistent output
-	keys := make([]string, 0, attrs.Len())
-	attrs.Range(func(k string, v pcommon.Value) bool {
-		keys = append(keys, k)
-		return true
-	})
-	sort.Strings(keys)
+// attribute is a single key/value pair prepared for display
+type attribute struct {
+	key   string
+	value pcommon.Value
+}
 
-	for _, key := range keys {
+// spanAttributes returns attributes sorted by key. With flatten set, nested
+// maps are expanded into dotted keys (e.g. http.request.header.content_type)
+// instead of being rendered inline.
+func spanAttributes(attrs pcommon.Map, flatten bool) []attribute {
+	var result []attribute
+	appendAttributes(&result, "", attrs, flatten)
+	return result
+}
+
+func appendAttributes(result *[]attribute, prefix string, attrs pcommon.Map, flatten bool) {
+	for _, key := range sortedKeys(attrs) {
 		val, _ := attrs.Get(key)
-		fmt.Fprintf(f, "- **%s**: %s\n", key, formatValue(val))
+		if flatten && val.Type() == pcommon.ValueTypeMap && val.Map().Len() > 0 {
+			appendAttributes(result, prefix+key+".", val.Map(), flatten)
+			continue
+		}
+		*result = append(*result, attribute{key: prefix + key, value: val})
 	}
 }
 
-func writeAttributesTable(f *os.File, attrs pcommon.Map) {
-	// Sort attributes by key for consistent output
+// sortedKeys returns attribute keys sorted for consistent output
+func sortedKeys(attrs pcommon.Map) []string {
 	keys := make([]string, 0, attrs.Len())
 	attrs.Range(func(k string, v pcommon.Value) bool {
 		keys = append(keys, k)
 		return true
 	})
 	sort.Strings(keys)
-
-	for _, key := range keys {
-		val, _ := attrs.Get(key)
-		fmt.Fprintf(f, "| %s | %s |\n", key, formatValue(val))
-	}
+	return keys
 }
 
+// Limits for rendering nested attribute values so a pathological value
+// (e.g. a serialized JSON blob stored as a structured map) stays readable
+const (
+	maxValueDepth    = 3
+	maxValueElements = 10
+)
+
 func formatValue(val pcommon.Value) string {
+	return formatValueDepth(val, 0)
+}
+
+func formatValueDepth(val pcommon.Value, depth int) string {
 	switch val.Type() {
 	case pcommon.ValueTypeStr:
-		return fmt.Sprintf("`%s`", val.Str())
+		return codeSpan(val.Str())
 	case pcommon.ValueTypeInt:
 		return fmt.Sprintf("`%d`", val.Int())
 	case pcommon.ValueTypeDouble:
@@ -678,18 +1996,34 @@ func formatValue(val pcommon.Value) string {
 	case pcommon.ValueTypeBytes:
 		return fmt.Sprintf("`%x`", val.Bytes().AsRaw())
 	case pcommon.ValueTypeSlice:
-		var items []string
 		slice := val.Slice()
-		for i := 0; i < slice.Len(); i++ {
-			items = append(items, formatValue(slice.At(i)))
+		if depth >= maxValueDepth {
+			return fmt.Sprintf("[… %d items]", slice.Len())
+		}
+		var items []string
+		for i := 0; i < slice.Len() && i < maxValueElements; i++ {
+			items = append(items, formatValueDepth(slice.At(i), depth+1))
+		}
+		if slice.Len() > maxValueElements {
+			items = append(items, fmt.Sprintf("… %d more", slice.Len()-maxValueElements))
 		}
 		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
 	case pcommon.ValueTypeMap:
+		m := val.Map()
+		if depth >= maxValueDepth {
+			return fmt.Sprintf("{… %d keys}", m.Len())
+		}
 		var pairs []string
-		val.Map().Range(func(k string, v pcommon.Value) bool {
-			pairs = append(pairs, fmt.Sprintf("%s: %s", k, formatValue(v)))
+		m.Range(func(k string, v pcommon.Value) bool {
+			if len(pairs) == maxValueElements {
+				return false
+			}
+			pairs = append(pairs, fmt.Sprintf("%s: %s", escapeMarkdown(k), formatValueDepth(v, depth+1)))
 			return true
 		})
+		if m.Len() > maxValueElements {
+			pairs = append(pairs, fmt.Sprintf("… %d more", m.Len()-maxValueElements))
+		}
 		return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
 	default:
 		return "`<unknown>`"
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var (
+	testConfigOnce sync.Once
+	testConfigVal  *Config
+)
+
+// testConfig returns the default configuration. Flags can only be
+// registered once per process, so every test shares it.
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	testConfigOnce.Do(func() {
+		testConfigVal = NewConfig()
+		if err := testConfigVal.Validate(); err != nil {
+			t.Fatalf("default config is invalid: %v", err)
+		}
+	})
+	return testConfigVal
+}
+
+// testSpan is a span in a test trace; a zero id or parent leaves it empty
+type testSpan struct {
+	id, parent byte
+	name       string
+}
+
+// newTestTrace builds a trace from spans, starting 1ms apart in the given order
+func newTestTrace(spans ...testSpan) *traceInfo {
+	ti := &traceInfo{traceID: "01020300000000000000000000000000"}
+	for i, ts := range spans {
+		span := ptrace.NewSpan()
+		span.SetTraceID(pcommon.TraceID([16]byte{1, 2, 3}))
+		if ts.id != 0 {
+			span.SetSpanID(pcommon.SpanID([8]byte{ts.id}))
+		}
+		if ts.parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID([8]byte{ts.parent}))
+		}
+		span.SetName(ts.name)
+		span.SetStartTimestamp(pcommon.Timestamp(1_000_000 * (i + 1)))
+		span.SetEndTimestamp(pcommon.Timestamp(1_000_000 * (i + 10)))
+		ti.spans = append(ti.spans, spanInfo{
+			span:     span,
+			resource: pcommon.NewResource(),
+			scope:    pcommon.NewInstrumentationScope(),
+		})
+	}
+	return ti
+}
+
+// renderTestTrace renders a single trace section as markdown
+func renderTestTrace(t *testing.T, ti *traceInfo) string {
+	t.Helper()
+	var out strings.Builder
+	writeTrace(&out, 1, ti, testConfig(t))
+	return out.String()
+}
+
+func TestBuildSpanTreeCycles(t *testing.T) {
+	t.Run("through root", func(t *testing.T) {
+		// No span is parentless, so the first span becomes the root and its
+		// parent reference loops back through the tree
+		ti := newTestTrace(testSpan{1, 2, "a"}, testSpan{2, 1, "b"})
+		tree := buildSpanTree(ti)
+		if len(tree.children) != 1 || tree.children[0].spanIndex != 2 {
+			t.Fatalf("expected span #2 under the root, got %d children", len(tree.children))
+		}
+		if !tree.children[0].cyclic {
+			t.Errorf("span #2 should be flagged as a cyclic parent reference")
+		}
+		if len(tree.detachedCycle) != 0 {
+			t.Errorf("no spans should be detached, got %v", tree.detachedCycle)
+		}
+		if out := renderTestTrace(t, ti); !strings.Contains(out, "⚠️ cyclic parent reference") {
+			t.Errorf("report doesn't flag the cycle:\n%s", out)
+		}
+	})
+
+	t.Run("without root", func(t *testing.T) {
+		ti := newTestTrace(
+			testSpan{1, 0, "root"},
+			testSpan{2, 1, "child"},
+			testSpan{3, 4, "a"},
+			testSpan{4, 3, "b"},
+			testSpan{5, 4, "below cycle"},
+		)
+		tree := buildSpanTree(ti)
+		if len(tree.children) != 1 {
+			t.Fatalf("expected one child under the root, got %d", len(tree.children))
+		}
+		want := []int{3, 4, 5}
+		if got := tree.detachedCycle; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+			t.Errorf("detached cycle spans = %v, want %v", got, want)
+		}
+		out := renderTestTrace(t, ti)
+		if !strings.Contains(out, "cyclic parent reference: 3 span(s) (#3, #4, #5)") {
+			t.Errorf("report doesn't flag the detached cycle:\n%s", out)
+		}
+	})
+
+	t.Run("orphans are not cycles", func(t *testing.T) {
+		ti := newTestTrace(testSpan{1, 0, "root"}, testSpan{2, 9, "orphan"}, testSpan{3, 2, "below orphan"})
+		if got := buildSpanTree(ti).detachedCycle; len(got) != 0 {
+			t.Errorf("orphaned spans reported as cyclic: %v", got)
+		}
+	})
+}
+
+func TestEmptySpanIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []testSpan
+		check func(t *testing.T, ti *traceInfo)
+	}{
+		{
+			name:  "empty IDs get separate nodes",
+			spans: []testSpan{{1, 0, "root"}, {0, 1, "first"}, {0, 1, "second"}, {0, 1, "third"}},
+			check: func(t *testing.T, ti *traceInfo) {
+				tree := buildSpanTree(ti)
+				if len(tree.children) != 3 {
+					t.Fatalf("expected 3 children under the root, got %d", len(tree.children))
+				}
+				for i, child := range tree.children {
+					if child.spanIndex != i+2 {
+						t.Errorf("child %d is span #%d, want #%d", i, child.spanIndex, i+2)
+					}
+				}
+			},
+		},
+		{
+			name:  "orphan with empty ID is not the root",
+			spans: []testSpan{{0, 0, "orphan"}, {1, 0, "root"}, {2, 1, "child"}},
+			check: func(t *testing.T, ti *traceInfo) {
+				root, ok := ti.findRootSpan()
+				if !ok || root.span.Name() != "root" {
+					t.Errorf("root span = %q, want %q", root.span.Name(), "root")
+				}
+				if tree := buildSpanTree(ti); len(tree.children) != 1 || tree.children[0].spanIndex != 3 {
+					t.Errorf("the empty-ID orphan was adopted by the root")
+				}
+			},
+		},
+		{
+			name:  "report flags empty IDs",
+			spans: []testSpan{{1, 0, "root"}, {0, 1, "first"}, {0, 1, "second"}},
+			check: func(t *testing.T, ti *traceInfo) {
+				out := renderTestTrace(t, ti)
+				if !strings.Contains(out, "2 span(s) have an empty span ID") {
+					t.Errorf("report doesn't count the empty span IDs:\n%s", out)
+				}
+				if strings.Count(out, "⚠️ NO SPAN ID") != 2 {
+					t.Errorf("timeline doesn't mark both spans without an ID:\n%s", out)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, newTestTrace(tt.spans...))
+		})
+	}
+}
+
+func TestTOCNumbersSplitParts(t *testing.T) {
+	a := newTestTrace(testSpan{1, 0, "first request"})
+	b := newTestTrace(testSpan{2, 0, "second request"})
+	a.collisionPart, a.collisionParts = "a", 2
+	b.collisionPart, b.collisionParts = "b", 2
+	traces := []*traceInfo{a, b}
+
+	var out strings.Builder
+	writeTOCTable(&out, traces, traces, testConfig(t))
+	want := "[#2](#" + traceAnchor(2, b, testConfig(t)) + ")"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("TOC doesn't link part b as trace #2 (%s):\n%s", want, out.String())
+	}
+}
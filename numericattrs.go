@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// numericAttrStats aggregates one numeric span attribute across a trace
+type numericAttrStats struct {
+	key      string
+	spans    int
+	min, max float64
+	sum      float64
+}
+
+// matchesNumericAttrKey reports whether an attribute key is selected by a
+// -numeric-attr-stats pattern: an exact key, or a prefix ending in "*" such
+// as process.runtime.*
+func matchesNumericAttrKey(key, pattern string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(key, prefix)
+	}
+	return key == pattern
+}
+
+// traceNumericAttrStats returns the min, max and sum of every int or double
+// span attribute matching -numeric-attr-stats, sorted by key. Nested map
+// attributes are matched by their dotted key.
+func traceNumericAttrStats(ti *traceInfo, config *Config) []numericAttrStats {
+	if len(config.NumericAttrStats) == 0 {
+		return nil
+	}
+
+	byKey := make(map[string]*numericAttrStats)
+	for _, si := range ti.spans {
+		for _, attr := range spanAttributes(si.span.Attributes(), true) {
+			var value float64
+			switch attr.value.Type() {
+			case pcommon.ValueTypeInt:
+				value = float64(attr.value.Int())
+			case pcommon.ValueTypeDouble:
+				value = attr.value.Double()
+			default:
+				continue
+			}
+			if !numericAttrSelected(attr.key, config) {
+				continue
+			}
+			stats, ok := byKey[attr.key]
+			if !ok {
+				stats = &numericAttrStats{key: attr.key, min: math.Inf(1), max: math.Inf(-1)}
+				byKey[attr.key] = stats
+			}
+			stats.spans++
+			stats.min = min(stats.min, value)
+			stats.max = max(stats.max, value)
+			stats.sum += value
+		}
+	}
+
+	result := make([]numericAttrStats, 0, len(byKey))
+	for _, stats := range byKey {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].key < result[j].key })
+	return result
+}
+
+// numericAttrSelected reports whether key matches any -numeric-attr-stats pattern
+func numericAttrSelected(key string, config *Config) bool {
+	for _, pattern := range config.NumericAttrStats {
+		if matchesNumericAttrKey(key, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatNumericValue renders an aggregated value without a trailing ".0"
+// for whole numbers
+func formatNumericValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeNumericAttrStats writes the markdown table of -numeric-attr-stats
+// aggregates for a trace
+func writeNumericAttrStats(f io.Writer, ti *traceInfo, config *Config) {
+	stats := traceNumericAttrStats(ti, config)
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Fprintf(f, "### Numeric Attributes\n")
+	table := newMarkdownTable("Attribute", "Spans", "Min", "Max", "Sum")
+	for _, s := range stats {
+		table.addRow(codeSpan(s.key), fmt.Sprintf("%d", s.spans),
+			formatNumericValue(s.min), formatNumericValue(s.max), formatNumericValue(s.sum))
+	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
+
+// writeHTMLNumericAttrStats writes the HTML equivalent of writeNumericAttrStats
+func writeHTMLNumericAttrStats(f io.Writer, ti *traceInfo, config *Config) {
+	stats := traceNumericAttrStats(ti, config)
+	if len(stats) == 0 {
+		return
+	}
+
+	fmt.Fprintf(f, "<h3>Numeric Attributes</h3>\n<table>\n")
+	fmt.Fprintf(f, "<tr><th>Attribute</th><th>Spans</th><th>Min</th><th>Max</th><th>Sum</th></tr>\n")
+	for _, s := range stats {
+		fmt.Fprintf(f, "<tr><td><code>%s</code></td><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(s.key), s.spans, formatNumericValue(s.min), formatNumericValue(s.max), formatNumericValue(s.sum))
+	}
+	fmt.Fprintf(f, "</table>\n")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openAPISpec returns the OpenAPI 3 description of the read API. Response
+// schemas are derived from the Go response types by reflection, so the spec
+// can't drift from what the handlers actually encode.
+var openAPISpec = sync.OnceValue(func() map[string]any {
+	schemas := make(map[string]any)
+	listRef := openAPISchema(reflect.TypeFor[apiTraceList](), schemas)
+	traceRef := openAPISchema(reflect.TypeFor[jsonTrace](), schemas)
+	servicesRef := openAPISchema(reflect.TypeFor[apiServiceList](), schemas)
+	errorResponse := map[string]any{"description": "Plain-text error message"}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "tracedown API",
+			"version": version,
+		},
+		"paths": map[string]any{
+			"/api/traces": map[string]any{
+				"get": map[string]any{
+					"operationId": "listTraces",
+					"summary":     "List the collected traces",
+					"responses": map[string]any{
+						"200": jsonResponse("Trace summaries in report order", listRef),
+						"401": errorResponse,
+					},
+				},
+			},
+			"/api/traces/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getTrace",
+					"summary":     "Get one trace with all its spans",
+					"parameters": []any{map[string]any{
+						"name":     "id",
+						"in":       "path",
+						"required": true,
+						"schema":   map[string]any{"type": "string"},
+					}},
+					"responses": map[string]any{
+						"200": jsonResponse("The trace", traceRef),
+						"401": errorResponse,
+						"404": errorResponse,
+					},
+				},
+			},
+			"/api/services": map[string]any{
+				"get": map[string]any{
+					"operationId": "listServices",
+					"summary":     "List the services seen with their trace, span and error counts",
+					"responses": map[string]any{
+						"200": jsonResponse("Services by descending span count", servicesRef),
+						"401": errorResponse,
+					},
+				},
+			},
+		},
+		"components": map[string]any{"schemas": schemas},
+	}
+})
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+// openAPISchema returns the schema for t. Named structs are added to schemas
+// as components and referenced by $ref.
+func openAPISchema(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := openAPISchema(t.Elem(), schemas)
+		return map[string]any{"allOf": []any{schema}, "nullable": true}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := openAPISchemaName(t)
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		// Register before walking fields so recursive types terminate
+		schemas[name] = nil
+		schemas[name] = openAPIStructSchema(t, schemas)
+		return ref
+	}
+	// Interfaces hold arbitrary attribute values
+	return map[string]any{}
+}
+
+// openAPIStructSchema describes a struct's JSON-encoded fields; fields
+// without omitempty are required
+func openAPIStructSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = openAPISchema(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// openAPISchemaName turns a response type name such as jsonTrace or
+// apiTraceList into a component name (Trace, TraceList)
+func openAPISchemaName(t reflect.Type) string {
+	name := t.Name()
+	for _, prefix := range []string{"json", "api"} {
+		if trimmed, ok := strings.CutPrefix(name, prefix); ok && trimmed != "" {
+			return trimmed
+		}
+	}
+	return name
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// outputFormat describes a report writer selected by output file extension
+type outputFormat struct {
+	name  string
+	write func(s *storageSnapshot, w io.Writer, config *Config) error
+}
+
+// outputFormats maps lowercase file extensions to their report writers
+var outputFormats = map[string]outputFormat{}
+
+// registerOutputFormat makes a report writer available for the given extensions
+func registerOutputFormat(format outputFormat, extensions ...string) {
+	for _, ext := range extensions {
+		outputFormats[strings.ToLower(ext)] = format
+	}
+}
+
+func init() {
+	registerOutputFormat(outputFormat{name: "markdown", write: (*storageSnapshot).WriteMarkdown}, ".md", ".markdown")
+	registerOutputFormat(outputFormat{name: "json", write: (*storageSnapshot).WriteJSON}, ".json")
+	registerOutputFormat(outputFormat{name: "html", write: (*storageSnapshot).WriteHTML}, ".html", ".htm")
+	registerOutputFormat(outputFormat{name: "csv", write: (*storageSnapshot).WriteCSV}, ".csv")
+}
+
+// outputFormatFor infers the report format from the output file extension
+func outputFormatFor(path string) (outputFormat, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if format, ok := outputFormats[ext]; ok {
+		return format, nil
+	}
+	return outputFormat{}, fmt.Errorf("unsupported output format for %q (supported extensions: %s)",
+		path, strings.Join(supportedExtensions(), ", "))
+}
+
+// supportedExtensions returns the registered output extensions in sorted order
+func supportedExtensions() []string {
+	exts := make([]string, 0, len(outputFormats))
+	for ext := range outputFormats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// WriteReports writes every configured output file from the same snapshot of
+// collected data, without holding the storage lock while rendering. When an
+// output can't be written, the report goes to a fallback file in the temp
+// directory so the collected data isn't lost; the original failure is still
+// returned. It returns the paths that were written.
+func (s *TraceStorage) WriteReports(config *Config) ([]string, error) {
+	s.ExpireOldTraces()
+	snapshot := s.Snapshot()
+	if config.anonymizer != nil {
+		snapshot.traces = config.anonymizer.anonymizeEntries(snapshot.traces)
+	}
+
+	now := time.Now()
+	var written []string
+	var failures []error
+	for _, output := range config.outputPaths() {
+		format, err := config.formatFor(output)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+
+		// The -export-otlp dump keeps its path so it can be replayed with -input
+		rotate := config.Rotate > 0 && output != config.ExportOTLP
+		path := output
+		if rotate {
+			path = rotatedPath(output, now)
+		}
+		err = writeReportFile(snapshot, path, format, config)
+		if err == nil {
+			written = append(written, path)
+			if rotate {
+				pruneRotated(output, config.Rotate)
+			}
+			continue
+		}
+		failures = append(failures, fmt.Errorf("failed to write %s report %s: %w", format.name, path, err))
+
+		fallback := fallbackPath(path)
+		if fallbackErr := writeReportFile(snapshot, fallback, format, config); fallbackErr != nil {
+			log.Printf("Failed to write fallback %s report %s: %v", format.name, fallback, fallbackErr)
+			continue
+		}
+		log.Printf("Could not write %s, %s report saved to fallback path %s", path, format.name, fallback)
+		written = append(written, fallback)
+	}
+
+	return written, errors.Join(failures...)
+}
+
+// rotatedTimestamp is the layout of the timestamp -rotate adds to report names
+const rotatedTimestamp = "20060102-150405"
+
+// rotatedPath inserts a timestamp before the extension, e.g. traces.md
+// becomes traces-20240115-103000.md
+func rotatedPath(path string, at time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + at.Format(rotatedTimestamp) + ext
+}
+
+// pruneRotated deletes the oldest timestamped reports for path so at most
+// keep remain. Only names produced by rotatedPath are considered.
+func pruneRotated(path string, keep int) {
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Warning: Failed to list old reports in %s: %v", dir, err)
+		return
+	}
+
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+	var rotated []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(rotatedTimestamp, stamp); err == nil {
+			rotated = append(rotated, filepath.Join(dir, name))
+		}
+	}
+
+	// Timestamps sort chronologically as strings
+	sort.Strings(rotated)
+	for len(rotated) > keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			log.Printf("Warning: Failed to remove old report %s: %v", rotated[0], err)
+		} else {
+			log.Printf("Removed old report %s (-rotate %d)", rotated[0], keep)
+		}
+		rotated = rotated[1:]
+	}
+}
+
+// checkOutputWritable verifies that the directory of an output file exists
+// and accepts new files, so a bad path is reported at startup rather than
+// after the whole collection run
+func checkOutputWritable(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("output directory for %s is not accessible: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("output directory for %s is not a directory: %s", path, dir)
+	}
+	f, err := os.CreateTemp(dir, ".tracedown-write-check-*")
+	if err != nil {
+		return fmt.Errorf("output directory for %s is not writable: %w", path, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// fallbackPath returns a temp-directory location for a report that couldn't
+// be written to its configured path
+func fallbackPath(path string) string {
+	name := fmt.Sprintf("tracedown-%s-%s", time.Now().Format("20060102-150405"), filepath.Base(path))
+	return filepath.Join(os.TempDir(), name)
+}
+
+// Reports with at least progressMinTraces traces log rendering progress
+// every tenth of the way, so a long report doesn't look hung
+const progressMinTraces = 1000
+
+// renderTraces renders every trace into its own buffer on a pool of workers,
+// then writes the buffers to f in report order. Traces are independent once
+// grouped, so rendering parallelizes cleanly; only the final writes are serial.
+//
+// With -dump-on-panic, a trace whose rendering panics is replaced by the
+// placeholder from failed and its raw OTLP is saved next to the report.
+func renderTraces(f io.Writer, traces []*traceInfo, config *Config,
+	render func(w io.Writer, index int, ti *traceInfo),
+	failed func(w io.Writer, index int, ti *traceInfo, reason string)) error {
+	buffers := make([]bytes.Buffer, len(traces))
+	next := make(chan int)
+
+	progressEvery := 0
+	if len(traces) >= progressMinTraces {
+		progressEvery = len(traces) / 10
+	}
+	var rendered atomic.Int64
+
+	var wg sync.WaitGroup
+	workers := min(runtime.GOMAXPROCS(0), len(traces))
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if config.DumpOnPanic {
+					renderRecovering(&buffers[i], i+1, traces[i], config, render, failed)
+				} else {
+					render(&buffers[i], i+1, traces[i])
+				}
+				if done := int(rendered.Add(1)); progressEvery > 0 && done%progressEvery == 0 && done < len(traces) {
+					log.Printf("Rendered %d/%d traces", done, len(traces))
+				}
+			}
+		}()
+	}
+	for i := range traces {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i := range buffers {
+		if _, err := buffers[i].WriteTo(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderRecovering renders one trace, writing a placeholder and dumping the
+// trace's raw OTLP if rendering panics
+func renderRecovering(buf *bytes.Buffer, index int, ti *traceInfo, config *Config,
+	render func(w io.Writer, index int, ti *traceInfo),
+	failed func(w io.Writer, index int, ti *traceInfo, reason string)) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		buf.Reset()
+
+		reason := fmt.Sprintf("rendering panicked: %v", r)
+		path := filepath.Join(filepath.Dir(config.OutputFiles[0]), fmt.Sprintf("tracedown-panic-%s.pb", ti.partID()))
+		if err := dumpTraceProto(ti, path); err != nil {
+			log.Printf("Warning: Trace %s failed to render (%v) and could not be dumped: %v", ti.partID(), r, err)
+		} else {
+			log.Printf("Warning: Trace %s failed to render (%v), raw OTLP saved to %s", ti.partID(), r, path)
+			reason += "; raw OTLP saved to " + path
+		}
+		failed(buf, index, ti, reason)
+	}()
+	render(buf, index, ti)
+}
+
+// dumpTraceProto writes a trace's spans to path as an OTLP protobuf
+// ExportTraceServiceRequest, so it can be replayed or inspected
+func dumpTraceProto(ti *traceInfo, path string) error {
+	traces := ptrace.NewTraces()
+	for _, si := range ti.spans {
+		rs := traces.ResourceSpans().AppendEmpty()
+		si.resource.CopyTo(rs.Resource())
+		rs.SetSchemaUrl(si.resourceSchemaURL)
+		ss := rs.ScopeSpans().AppendEmpty()
+		si.scope.CopyTo(ss.Scope())
+		ss.SetSchemaUrl(si.scopeSchemaURL)
+		si.span.CopyTo(ss.Spans().AppendEmpty())
+	}
+
+	data, err := ptraceotlp.NewExportRequestFromTraces(traces).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal trace: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// writeReportFile renders a report into a temp file next to path and renames
+// it into place only once fully written, so an interrupted shutdown never
+// leaves a truncated report behind
+func writeReportFile(s *storageSnapshot, path string, format outputFormat, config *Config) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	tmpPath := f.Name()
+
+	err = format.write(s, f, config)
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanSamplingProbability returns the probability with which a span was
+// sampled, read from the OpenTelemetry "ot" tracestate entry when present and
+// falling back to defaultRate (0 = not configured, every span was kept)
+func spanSamplingProbability(span ptrace.Span, defaultRate float64) float64 {
+	if p, ok := parseTraceStateProbability(span.TraceState().AsRaw()); ok {
+		return p
+	}
+	if defaultRate > 0 {
+		return defaultRate
+	}
+	return 1
+}
+
+// spanAdjustedCount returns how many spans this one represents after head
+// sampling, or 0 when the recorded probability is zero
+func spanAdjustedCount(span ptrace.Span, defaultRate float64) float64 {
+	p := spanSamplingProbability(span, defaultRate)
+	if p <= 0 {
+		return 0
+	}
+	return 1 / p
+}
+
+// parseTraceStateProbability extracts the sampling probability from the "ot"
+// member of a W3C tracestate. Both the threshold form (th:<hex>) and the
+// older power-of-two form (p:<exponent>) are understood.
+func parseTraceStateProbability(traceState string) (float64, bool) {
+	for _, member := range parseTraceState(traceState) {
+		if member.key != "ot" {
+			continue
+		}
+		for _, field := range strings.Split(member.value, ";") {
+			name, arg, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			switch name {
+			case "th":
+				return parseSamplingThreshold(arg)
+			case "p":
+				exp, err := strconv.Atoi(arg)
+				if err != nil || exp < 0 || exp > 63 {
+					return 0, false
+				}
+				if exp == 63 {
+					return 0, true
+				}
+				return math.Pow(2, -float64(exp)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// traceStateMember is one vendor entry of a W3C tracestate header
+type traceStateMember struct {
+	key   string
+	value string
+}
+
+// parseTraceState splits a W3C tracestate ("vendor1=value1,vendor2=value2")
+// into its members in order, skipping empty and malformed entries
+func parseTraceState(traceState string) []traceStateMember {
+	var members []traceStateMember
+	for _, member := range strings.Split(traceState, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || key == "" {
+			continue
+		}
+		members = append(members, traceStateMember{key: key, value: value})
+	}
+	return members
+}
+
+// parseSamplingThreshold converts a tracestate rejection threshold (up to 14
+// hex digits, trailing zeros omitted) into a sampling probability
+func parseSamplingThreshold(th string) (float64, bool) {
+	if th == "" || len(th) > 14 {
+		return 0, false
+	}
+	threshold, err := strconv.ParseUint(th+strings.Repeat("0", 14-len(th)), 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return 1 - float64(threshold)/float64(uint64(1)<<56), true
+}
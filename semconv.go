@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// semconvRule lists the attributes a span of a kind is expected to carry
+// once it has any attribute with prefix. Each requirement accepts the
+// current semantic-convention name first, then older names.
+type semconvRule struct {
+	prefix   string
+	kind     ptrace.SpanKind
+	required [][]string
+}
+
+// semconvRules are the -check-semconv expectations
+var semconvRules = []semconvRule{
+	{prefix: "http.", kind: ptrace.SpanKindServer, required: [][]string{
+		{"http.request.method", "http.method"},
+		{"http.response.status_code", "http.status_code"},
+		{"http.route"},
+	}},
+	{prefix: "http.", kind: ptrace.SpanKindClient, required: [][]string{
+		{"http.request.method", "http.method"},
+		{"http.response.status_code", "http.status_code"},
+		{"server.address", "net.peer.name"},
+	}},
+	{prefix: "db.", kind: ptrace.SpanKindClient, required: [][]string{
+		{"db.system.name", "db.system"},
+		{"db.operation.name", "db.operation"},
+	}},
+}
+
+// missingSemconvAttributes returns the expected semantic-convention
+// attributes a span lacks, by their current names
+func missingSemconvAttributes(span ptrace.Span) []string {
+	var missing []string
+	for _, rule := range semconvRules {
+		if span.Kind() != rule.kind || !hasAttributePrefix(span, rule.prefix) {
+			continue
+		}
+		for _, names := range rule.required {
+			if !hasAnyAttribute(span, names) {
+				missing = append(missing, names[0])
+			}
+		}
+	}
+	return missing
+}
+
+// semconvNote warns about missing semantic-convention attributes when
+// -check-semconv is set, e.g. "⚠️ semconv: missing http.route", or returns ""
+func semconvNote(span ptrace.Span, config *Config) string {
+	if !config.CheckSemconv {
+		return ""
+	}
+	missing := missingSemconvAttributes(span)
+	if len(missing) == 0 {
+		return ""
+	}
+	return "⚠️ semconv: missing " + strings.Join(missing, ", ")
+}
+
+func hasAttributePrefix(span ptrace.Span, prefix string) bool {
+	found := false
+	span.Attributes().Range(func(k string, _ pcommon.Value) bool {
+		found = strings.HasPrefix(k, prefix)
+		return !found
+	})
+	return found
+}
+
+func hasAnyAttribute(span ptrace.Span, names []string) bool {
+	for _, name := range names {
+		if _, ok := span.Attributes().Get(name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// serviceCall is one cross-service request: a SERVER span whose parent is a
+// CLIENT span in another service
+type serviceCall struct {
+	from, to   string
+	operation  string
+	start, end pcommon.Timestamp
+}
+
+// findServiceCalls pairs CLIENT spans with their SERVER children in other
+// services, ordered by start time
+func findServiceCalls(ti *traceInfo, config *Config) []serviceCall {
+	clients := make(map[pcommon.SpanID]spanInfo)
+	for _, si := range ti.spans {
+		if si.span.Kind() == ptrace.SpanKindClient && !si.span.SpanID().IsEmpty() {
+			clients[si.span.SpanID()] = si
+		}
+	}
+
+	var calls []serviceCall
+	for _, si := range ti.spans {
+		if si.span.Kind() != ptrace.SpanKindServer {
+			continue
+		}
+		client, ok := clients[si.span.ParentSpanID()]
+		if !ok {
+			continue
+		}
+		from, _ := spanServiceName(client.resource, client.span, config)
+		to, _ := spanServiceName(si.resource, si.span, config)
+		if from == to {
+			continue
+		}
+		calls = append(calls, serviceCall{
+			from:      from,
+			to:        to,
+			operation: si.span.Name(),
+			start:     client.span.StartTimestamp(),
+			end:       client.span.EndTimestamp(),
+		})
+	}
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].start < calls[j].start })
+	return calls
+}
+
+// writeSequenceDiagram writes a Mermaid sequence diagram of the trace's
+// cross-service calls, with each call's response drawn when it completed.
+// Nothing is written when the trace stays within one service.
+func writeSequenceDiagram(f io.Writer, ti *traceInfo, config *Config) {
+	calls := findServiceCalls(ti, config)
+	if len(calls) == 0 {
+		return
+	}
+
+	// Participants appear in the order they first take part
+	ids := make(map[string]string)
+	var participants []string
+	for _, call := range calls {
+		for _, service := range []string{call.from, call.to} {
+			if _, ok := ids[service]; !ok {
+				ids[service] = fmt.Sprintf("P%d", len(ids)+1)
+				participants = append(participants, service)
+			}
+		}
+	}
+
+	// Interleave requests and responses in time order
+	type message struct {
+		at   pcommon.Timestamp
+		line string
+	}
+	var messages []message
+	for _, call := range calls {
+		messages = append(messages,
+			message{call.start, fmt.Sprintf("%s->>%s: %s", ids[call.from], ids[call.to], mermaidText(call.operation))},
+			message{call.end, fmt.Sprintf("%s-->>%s: %v", ids[call.to], ids[call.from], time.Duration(call.end-call.start))})
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].at < messages[j].at })
+
+	fmt.Fprintf(f, "### Sequence Diagram\n")
+	fmt.Fprintf(f, "```mermaid\nsequenceDiagram\n")
+	for _, service := range participants {
+		fmt.Fprintf(f, "    participant %s as %s\n", ids[service], mermaidText(service))
+	}
+	for _, m := range messages {
+		fmt.Fprintf(f, "    %s\n", m.line)
+	}
+	fmt.Fprintf(f, "```\n\n")
+}
+
+// mermaidText makes a label safe for a Mermaid sequence diagram, where
+// semicolons and newlines end a statement and # starts an entity code
+var mermaidText = strings.NewReplacer("#", "#35;", ";", "#59;", "\n", " ", "\r", " ").Replace
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// serviceStats summarizes the spans one service has sent
+type serviceStats struct {
+	Service    string  `json:"service"`
+	Traces     int     `json:"traces"`
+	Spans      int     `json:"spans"`
+	ErrorSpans int     `json:"error_spans"`
+	ErrorRate  float64 `json:"error_rate"` // fraction of the service's spans with error status
+}
+
+// computeServiceStats counts traces, spans and error spans per service,
+// sorted by descending span count then name. A trace counts towards every
+// service that contributed a span to it.
+func computeServiceStats(traces []*traceInfo, config *Config) []serviceStats {
+	byService := make(map[string]*serviceStats)
+	for _, ti := range traces {
+		seen := make(map[string]bool)
+		for _, si := range ti.spans {
+			name, _ := spanServiceName(si.resource, si.span, config)
+			stats, ok := byService[name]
+			if !ok {
+				stats = &serviceStats{Service: name}
+				byService[name] = stats
+			}
+			if !seen[name] {
+				seen[name] = true
+				stats.Traces++
+			}
+			stats.Spans++
+			if si.span.Status().Code() == ptrace.StatusCodeError {
+				stats.ErrorSpans++
+			}
+		}
+	}
+
+	services := make([]serviceStats, 0, len(byService))
+	for _, stats := range byService {
+		stats.ErrorRate = float64(stats.ErrorSpans) / float64(stats.Spans)
+		services = append(services, *stats)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Spans != services[j].Spans {
+			return services[i].Spans > services[j].Spans
+		}
+		return services[i].Service < services[j].Service
+	})
+	return services
+}
+
+// writeServiceStats writes the inventory of services seen in the report
+func writeServiceStats(f io.Writer, traces []*traceInfo, config *Config) {
+	services := computeServiceStats(traces, config)
+	if len(services) == 0 {
+		return
+	}
+
+	fmt.Fprintf(f, "## Services\n\n")
+	table := newMarkdownTable("Service", "Traces", "Spans", "Error Spans", "Error Rate")
+	for _, stats := range services {
+		table.addRow(escapeMarkdown(stats.Service), fmt.Sprintf("%d", stats.Traces), fmt.Sprintf("%d", stats.Spans),
+			fmt.Sprintf("%d", stats.ErrorSpans), fmt.Sprintf("%.1f%%", stats.ErrorRate*100))
+	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
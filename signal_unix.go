@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyClearSignal relays SIGUSR1, which clears stored traces, to c
+func notifyClearSignal(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
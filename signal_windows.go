@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyClearSignal is a no-op: Windows has no SIGUSR1, use POST /api/clear instead
+func notifyClearSignal(c chan<- os.Signal) {}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxSkewedSpansListed caps the span numbers named in a skew note
+const maxSkewedSpansListed = 5
+
+// rootSkew describes spans that started before their trace's root span,
+// usually because of clock skew between hosts or a span parented to the
+// wrong trace
+type rootSkew struct {
+	spanNumbers []int         // spans starting before the root, in start order
+	lead        time.Duration // how far the earliest one precedes the root
+}
+
+// spansBeforeRoot finds the spans that started before the trace's root span.
+// Traces without a parentless root aren't checked.
+func (ti *traceInfo) spansBeforeRoot() (rootSkew, bool) {
+	root, ok := ti.findRootSpan()
+	if !ok || !root.span.ParentSpanID().IsEmpty() {
+		return rootSkew{}, false
+	}
+	rootStart := root.span.StartTimestamp()
+
+	var skew rootSkew
+	for i, si := range ti.spans {
+		if start := si.span.StartTimestamp(); start < rootStart {
+			skew.spanNumbers = append(skew.spanNumbers, i+1)
+			skew.lead = max(skew.lead, time.Duration(rootStart-start))
+		}
+	}
+	return skew, len(skew.spanNumbers) > 0
+}
+
+// note returns the warning shown on traces with skewed spans
+func (s rootSkew) note() string {
+	var spans []string
+	for i, n := range s.spanNumbers {
+		if i == maxSkewedSpansListed {
+			spans = append(spans, fmt.Sprintf("%d more", len(s.spanNumbers)-i))
+			break
+		}
+		spans = append(spans, fmt.Sprintf("#%d", n))
+	}
+	return fmt.Sprintf("Clock skew: %d span(s) started before the root span, the earliest by %v (%s). Timeline offsets are measured from the earliest span start, not the root's.",
+		len(s.spanNumbers), s.lead, strings.Join(spans, ", "))
+}
+
+// countSkewedTraces returns how many traces have spans starting before their root
+func countSkewedTraces(traces []*traceInfo) int {
+	count := 0
+	for _, ti := range traces {
+		if _, ok := ti.spansBeforeRoot(); ok {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanMatchRule matches spans whose name (key "name") or attribute equals value
+type spanMatchRule struct {
+	key   string
+	value string
+}
+
+// parseSpanMatchRule parses a "key=value" rule
+func parseSpanMatchRule(rule string) (spanMatchRule, error) {
+	key, value, ok := strings.Cut(rule, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return spanMatchRule{}, fmt.Errorf("invalid span match rule %q (expected key=value)", rule)
+	}
+	return spanMatchRule{key: key, value: value}, nil
+}
+
+func (r spanMatchRule) matches(span ptrace.Span) bool {
+	if r.key == "name" {
+		return span.Name() == r.value
+	}
+	val, ok := span.Attributes().Get(r.key)
+	return ok && val.AsString() == r.value
+}
+
+// matchesAnyRule reports whether span matches at least one rule
+func matchesAnyRule(span ptrace.Span, rules []spanMatchRule) bool {
+	for _, rule := range rules {
+		if rule.matches(span) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropMatchingSpans removes spans matching any rule from a batch, along with
+// their descendants in the same batch, and returns how many were removed.
+// Resource and scope groups left empty are removed too.
+func dropMatchingSpans(traces ptrace.Traces, rules []spanMatchRule) int {
+	matched := false
+	dropped := make(map[pcommon.SpanID]bool)
+	forEachSpan(traces, func(span ptrace.Span) {
+		if matchesAnyRule(span, rules) {
+			matched = true
+			// A span without an ID can't be anyone's parent
+			if !span.SpanID().IsEmpty() {
+				dropped[span.SpanID()] = true
+			}
+		}
+	})
+	if !matched {
+		return 0
+	}
+
+	// Extend the set to descendants until no new spans are added
+	for added := len(dropped) > 0; added; {
+		added = false
+		forEachSpan(traces, func(span ptrace.Span) {
+			if !span.SpanID().IsEmpty() && !dropped[span.SpanID()] && dropped[span.ParentSpanID()] {
+				dropped[span.SpanID()] = true
+				added = true
+			}
+		})
+	}
+
+	removed := 0
+	traces.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				drop := dropped[span.SpanID()] || dropped[span.ParentSpanID()] || matchesAnyRule(span, rules)
+				if drop {
+					removed++
+				}
+				return drop
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return removed
+}
+
+// forEachSpan calls fn for every span in a batch
+func forEachSpan(traces ptrace.Traces, fn func(ptrace.Span)) {
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		rs := traces.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				fn(spans.At(k))
+			}
+		}
+	}
+}
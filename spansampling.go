@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanSample is a trace's -max-spans-stored-per-trace sampling state. Spans
+// are kept when their span ID hash falls below a threshold that halves each
+// time the trace goes over the limit, so the kept spans are an even sample
+// of the whole trace rather than the first ones to arrive.
+type spanSample struct {
+	stored int  // sampleable spans currently stored
+	level  uint // keep spans whose hash has its top level bits clear
+}
+
+// keeps reports whether a sampleable span survives the trace's current level
+func (s *spanSample) keeps(span ptrace.Span) bool {
+	return s.level == 0 || spanSampleHash(span)>>(64-s.level) == 0
+}
+
+// sampleable reports whether a span can be dropped by per-trace sampling.
+// Root and error spans are always kept so the trace structure and its
+// failures survive.
+func sampleable(span ptrace.Span) bool {
+	return !span.ParentSpanID().IsEmpty() && span.Status().Code() != ptrace.StatusCodeError
+}
+
+// spanSampleHash maps a span ID to an evenly distributed value (the
+// splitmix64 finalizer), so sequential IDs are spread out too
+func spanSampleHash(span ptrace.Span) uint64 {
+	id := span.SpanID()
+	x := binary.BigEndian.Uint64(id[:]) + 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// sampleSpansLocked drops spans from a batch that their trace's sampling
+// level rejects. When a trace goes over MaxSpansStoredPerTrace its level is
+// raised and the spans already stored are thinned to match.
+// Must be called with lock held
+func (s *TraceStorage) sampleSpansLocked(traces ptrace.Traces) {
+	limit := s.config.MaxSpansStoredPerTrace
+	over := make(map[string]bool)
+	dropped := removeSpansIf(traces, func(span ptrace.Span) bool {
+		if !sampleable(span) {
+			return false
+		}
+		traceID := span.TraceID().String()
+		sample, ok := s.spanSamples[traceID]
+		if ok && !sample.keeps(span) {
+			return true
+		}
+		if !ok {
+			sample = &spanSample{}
+			s.spanSamples[traceID] = sample
+		}
+		sample.stored++
+		if sample.stored > limit {
+			over[traceID] = true
+		}
+		return false
+	})
+	if len(over) > 0 {
+		dropped += s.raiseSampleLevelsLocked(traces, over)
+	}
+
+	if dropped > 0 {
+		s.sampledSpans += dropped
+		log.Printf("Warning: Dropped %d spans from traces over the per-trace limit (%d spans)", dropped, limit)
+	}
+}
+
+// raiseSampleLevelsLocked raises the sampling level of the over-limit traces
+// until they fit, then removes the spans the new levels reject from the
+// batch and from stored batches. Returns how many spans were removed.
+// Must be called with lock held
+func (s *TraceStorage) raiseSampleLevelsLocked(batch ptrace.Traces, over map[string]bool) int {
+	hashes := make(map[string][]uint64)
+	collect := func(span ptrace.Span) {
+		if traceID := span.TraceID().String(); over[traceID] && sampleable(span) {
+			hashes[traceID] = append(hashes[traceID], spanSampleHash(span))
+		}
+	}
+	for _, entry := range s.traces {
+		forEachSpan(entry.traces, collect)
+	}
+	forEachSpan(batch, collect)
+
+	limit := s.config.MaxSpansStoredPerTrace
+	for traceID, traceHashes := range hashes {
+		sample := s.spanSamples[traceID]
+		for sample.stored > limit && sample.level < 64 {
+			sample.level++
+			sample.stored = 0
+			for _, h := range traceHashes {
+				if h>>(64-sample.level) == 0 {
+					sample.stored++
+				}
+			}
+		}
+		s.config.debugf("Trace %s over the per-trace limit: keeping 1 in %d sampleable spans", traceID, uint64(1)<<min(sample.level, 63))
+	}
+
+	rejected := func(span ptrace.Span) bool {
+		traceID := span.TraceID().String()
+		return over[traceID] && sampleable(span) && !s.spanSamples[traceID].keeps(span)
+	}
+	removed := removeSpansIf(batch, rejected)
+
+	// Stored batches are replaced rather than edited in place: snapshots
+	// being rendered share them
+	entries := make([]traceEntry, 0, len(s.traces))
+	for _, entry := range s.traces {
+		if !anySpan(entry.traces, rejected) {
+			entries = append(entries, entry)
+			continue
+		}
+		thinned := ptrace.NewTraces()
+		entry.traces.CopyTo(thinned)
+		removed += removeSpansIf(thinned, rejected)
+
+		s.subtractEntryTotals(entry)
+		if thinned.ResourceSpans().Len() == 0 {
+			continue
+		}
+		spanCount := s.countSpans(thinned)
+		entry.traces = thinned
+		entry.sizeBytes = s.estimateSize(thinned, spanCount)
+		entry.serviceBytes = s.estimateServiceSizes(thinned)
+		s.totalSizeBytes += entry.sizeBytes
+		s.totalSpanCount += spanCount
+		for service, size := range entry.serviceBytes {
+			s.serviceBytes[service] += size
+		}
+		entries = append(entries, entry)
+	}
+	s.traces = entries
+	return removed
+}
+
+// forgetStoredSpans decrements the per-trace sampling counts for a removed batch
+// Must be called with lock held
+func (s *TraceStorage) forgetStoredSpans(traces ptrace.Traces) {
+	forEachSpan(traces, func(span ptrace.Span) {
+		if !sampleable(span) {
+			return
+		}
+		traceID := span.TraceID().String()
+		if sample, ok := s.spanSamples[traceID]; ok {
+			sample.stored--
+			if sample.stored <= 0 {
+				delete(s.spanSamples, traceID)
+			}
+		}
+	})
+}
+
+// removeSpansIf removes the spans matching fn from a batch, along with
+// resource and scope groups left empty, and returns how many were removed
+func removeSpansIf(traces ptrace.Traces, fn func(ptrace.Span) bool) int {
+	removed := 0
+	traces.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				if fn(span) {
+					removed++
+					return true
+				}
+				return false
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return removed
+}
+
+// anySpan reports whether fn matches any span in a batch
+func anySpan(traces ptrace.Traces, fn func(ptrace.Span) bool) bool {
+	found := false
+	forEachSpan(traces, func(span ptrace.Span) {
+		found = found || fn(span)
+	})
+	return found
+}
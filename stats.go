@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// operationStats aggregates span durations for one operation (span name)
+type operationStats struct {
+	name      string
+	durations []time.Duration
+
+	// estimated is the span count extrapolated from head sampling probabilities
+	estimated float64
+}
+
+func (o *operationStats) count() int {
+	return len(o.durations)
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations
+func (o *operationStats) percentile(p float64) time.Duration {
+	if len(o.durations) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(o.durations))))
+	if rank < 1 {
+		rank = 1
+	}
+	return o.durations[rank-1]
+}
+
+// sparkBlocks are the bar heights used by sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline buckets the durations into len(sparkBlocks) equal-width bins
+// between the fastest and slowest span and draws each bin's count as a bar.
+// Empty bins get the lowest bar; the fullest bin gets the highest.
+func (o *operationStats) sparkline() string {
+	if len(o.durations) == 0 {
+		return ""
+	}
+	bins := len(sparkBlocks)
+	counts := make([]int, bins)
+	lo, hi := o.durations[0], o.durations[len(o.durations)-1]
+	for _, d := range o.durations {
+		bin := bins / 2 // identical durations land in the middle
+		if hi > lo {
+			bin = min(int(float64(d-lo)/float64(hi-lo)*float64(bins)), bins-1)
+		}
+		counts[bin]++
+	}
+	return sparkBars(counts)
+}
+
+// sparkBars draws one bar per count, scaled so the largest count gets the
+// highest bar and zero counts the lowest
+func sparkBars(counts []int) string {
+	peak := 0
+	for _, c := range counts {
+		peak = max(peak, c)
+	}
+	levels := len(sparkBlocks)
+	line := make([]rune, len(counts))
+	for i, c := range counts {
+		level := 0
+		if c > 0 {
+			level = 1 + int(math.Round(float64(c)/float64(peak)*float64(levels-2)))
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
+
+// computeOperationStats groups span durations by operation name, sorted by
+// descending count then name. It also reports whether any span was head
+// sampled, in which case the extrapolated counts differ from the observed ones.
+func computeOperationStats(traces []*traceInfo, sampleRate float64) ([]*operationStats, bool) {
+	byName := make(map[string]*operationStats)
+	sampled := false
+	for _, ti := range traces {
+		for _, si := range ti.spans {
+			span := si.span
+			addOperationDuration(byName, span.Name(), time.Duration(span.EndTimestamp()-span.StartTimestamp()))
+			adjusted := spanAdjustedCount(span, sampleRate)
+			byName[span.Name()].estimated += adjusted
+			if adjusted != 1 {
+				sampled = true
+			}
+		}
+	}
+	return sortOperationStats(byName), sampled
+}
+
+func addOperationDuration(byName map[string]*operationStats, name string, duration time.Duration) {
+	op, ok := byName[name]
+	if !ok {
+		op = &operationStats{name: name}
+		byName[name] = op
+	}
+	op.durations = append(op.durations, duration)
+}
+
+func sortOperationStats(byName map[string]*operationStats) []*operationStats {
+	ops := make([]*operationStats, 0, len(byName))
+	for _, op := range byName {
+		sort.Slice(op.durations, func(i, j int) bool {
+			return op.durations[i] < op.durations[j]
+		})
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].count() != ops[j].count() {
+			return ops[i].count() > ops[j].count()
+		}
+		return ops[i].name < ops[j].name
+	})
+	return ops
+}
+
+// loadBaseline reads operation statistics from a previous JSON report
+func loadBaseline(path string) (map[string]*operationStats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var report jsonReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+
+	byName := make(map[string]*operationStats)
+	for _, jt := range report.Traces {
+		for _, js := range jt.Spans {
+			addOperationDuration(byName, js.Name, time.Duration(js.DurationNs))
+		}
+	}
+
+	baseline := make(map[string]*operationStats, len(byName))
+	for _, op := range sortOperationStats(byName) {
+		baseline[op.name] = op
+	}
+	return baseline, nil
+}
+
+// formatDelta renders the change from baseline to current as an arrow and percentage
+func formatDelta(current, baseline time.Duration) string {
+	if baseline == 0 {
+		if current == 0 {
+			return "= 0.0%"
+		}
+		return "↑ new"
+	}
+	change := (float64(current) - float64(baseline)) / float64(baseline) * 100
+	switch {
+	case change > 0:
+		return fmt.Sprintf("↑ %.1f%%", change)
+	case change < 0:
+		return fmt.Sprintf("↓ %.1f%%", -change)
+	default:
+		return "= 0.0%"
+	}
+}
+
+func writeOperationStats(f io.Writer, traces []*traceInfo, config *Config) {
+	ops, sampled := computeOperationStats(traces, config.SampleRate)
+	if len(ops) == 0 {
+		return
+	}
+
+	fmt.Fprintf(f, "## Operation Statistics\n\n")
+	if sampled {
+		fmt.Fprintf(f, "Spans were head sampled; Est. Count extrapolates the observed count using each span's sampling probability.\n\n")
+	}
+	if config.baseline != nil {
+		fmt.Fprintf(f, "Compared against baseline `%s`.\n\n", config.BaselineFile)
+	}
+
+	headers := []string{"Operation", "Count"}
+	if sampled {
+		headers = append(headers, "Est. Count")
+	}
+	headers = append(headers, "p50", "p99", "Distribution")
+	if config.baseline != nil {
+		headers = append(headers, "Δ p50", "Δ p99")
+	}
+	table := newMarkdownTable(headers...)
+
+	for _, op := range ops {
+		p50 := op.percentile(50)
+		p99 := op.percentile(99)
+
+		cells := []string{escapeMarkdown(op.name), fmt.Sprintf("%d", op.count())}
+		if sampled {
+			cells = append(cells, fmt.Sprintf("~%.0f", op.estimated))
+		}
+		cells = append(cells, p50.String(), p99.String(), "`"+op.sparkline()+"`")
+
+		if config.baseline != nil {
+			deltaP50, deltaP99 := "_not in baseline_", "_not in baseline_"
+			if base, ok := config.baseline[op.name]; ok {
+				deltaP50 = formatDelta(p50, base.percentile(50))
+				deltaP99 = formatDelta(p99, base.percentile(99))
+			}
+			cells = append(cells, deltaP50, deltaP99)
+		}
+		table.addRow(cells...)
+	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
+
+// errorGroup counts error spans sharing a service, operation and message
+type errorGroup struct {
+	service, operation, message string
+	count                       int
+}
+
+// writeErrorSummary aggregates error spans by service, operation and status
+// message, for reports that leave out the per-trace detail
+func writeErrorSummary(f io.Writer, traces []*traceInfo, config *Config) {
+	errorTraces := 0
+	byKey := make(map[errorGroup]*errorGroup)
+	for _, ti := range traces {
+		if ti.hasError() {
+			errorTraces++
+		}
+		for _, si := range ti.spans {
+			if si.span.Status().Code() != ptrace.StatusCodeError {
+				continue
+			}
+			service, _ := spanServiceName(si.resource, si.span, config)
+			key := errorGroup{
+				service:   service,
+				operation: si.span.Name(),
+				message:   truncateText(strings.Join(strings.Fields(si.span.Status().Message()), " "), maxStatusSnippet),
+			}
+			if byKey[key] == nil {
+				group := key
+				byKey[key] = &group
+			}
+			byKey[key].count++
+		}
+	}
+
+	fmt.Fprintf(f, "## Errors\n\n")
+	fmt.Fprintf(f, "%d of %d traces (%.1f%%) have errors.\n\n", errorTraces, len(traces), float64(errorTraces)/float64(len(traces))*100)
+	if len(byKey) == 0 {
+		return
+	}
+
+	groups := make([]*errorGroup, 0, len(byKey))
+	for _, group := range byKey {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		if groups[i].service != groups[j].service {
+			return groups[i].service < groups[j].service
+		}
+		if groups[i].operation != groups[j].operation {
+			return groups[i].operation < groups[j].operation
+		}
+		return groups[i].message < groups[j].message
+	})
+
+	table := newMarkdownTable("Service", "Operation", "Status Message", "Error Spans")
+	for _, group := range groups {
+		table.addRow(escapeMarkdown(group.service), escapeMarkdown(group.operation), escapeMarkdown(group.message), fmt.Sprintf("%d", group.count))
+	}
+	table.write(f, config.Pretty)
+	fmt.Fprintf(f, "\n")
+}
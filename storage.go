@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,27 +13,88 @@ import (
 
 // traceEntry holds a trace batch with metadata
 type traceEntry struct {
-	traces    ptrace.Traces
-	timestamp time.Time
-	sizeBytes int64
+	traces       ptrace.Traces
+	timestamp    time.Time
+	sizeBytes    int64
+	serviceBytes map[string]int64
 }
 
 // TraceStorage holds collected traces in memory with limits
 type TraceStorage struct {
-	mu              sync.RWMutex
-	traces          []traceEntry
-	config          *Config
-	totalSizeBytes  int64
-	totalSpanCount  int
-	droppedTraces   int
-	droppedOldest   int
+	mu             sync.RWMutex
+	traces         []traceEntry
+	config         *Config
+	totalSizeBytes int64
+	totalSpanCount int
+	droppedTraces  int
+	droppedOldest  int
+	serviceBytes   map[string]int64
+
+	// Monotonic counts of everything stored over the whole run; unlike the
+	// gauges above they don't shrink on eviction, expiration or Clear
+	lifetimeBatches int
+	lifetimeSpans   int
+
+	// Trace completion tracking (only used when TraceTimeout is set)
+	pendingTraces      map[string]*pendingTrace
+	recentlyCompleted  map[string]time.Time // completion time, so late spans don't re-open the trace
+	completedTraces    int
+	completionHandlers []func(completedTrace)
+
+	// Spans removed by DropSpanMatching rules
+	filteredSpans int
+
+	// Spans received with an all-zero trace ID; they're stored but kept out
+	// of the grouped traces
+	malformedTraceIDSpans int
+
+	// Per-trace span sampling (only used when MaxSpansStoredPerTrace is set)
+	spanSamples  map[string]*spanSample
+	sampledSpans int
+
+	// Exporters that have sent traces, keyed by protocol, host and user agent
+	senders map[string]*senderInfo
+}
+
+// senderInfo describes an exporter that has sent traces to tracedown
+type senderInfo struct {
+	protocol  string
+	host      string
+	userAgent string
+	firstSeen time.Time
+	lastSeen  time.Time
+	requests  int
+}
+
+// pendingTrace tracks span arrivals for a trace that hasn't timed out yet
+type pendingTrace struct {
+	firstArrival time.Time
+	lastArrival  time.Time
+	spanCount    int
+}
+
+// completedTraceMemory is how many TraceTimeouts a completed trace ID is
+// remembered for; spans arriving for it in that window are late spans of the
+// completed trace rather than a new one
+const completedTraceMemory = 10
+
+// completedTrace describes a trace that received no new spans within TraceTimeout
+type completedTrace struct {
+	traceID   string
+	spanCount int
+	duration  time.Duration // time between first and last span arrival
 }
 
 // NewTraceStorage creates a new trace storage instance
 func NewTraceStorage(config *Config) *TraceStorage {
 	return &TraceStorage{
-		traces: make([]traceEntry, 0),
-		config: config,
+		traces:            make([]traceEntry, 0),
+		config:            config,
+		serviceBytes:      make(map[string]int64),
+		pendingTraces:     make(map[string]*pendingTrace),
+		recentlyCompleted: make(map[string]time.Time),
+		spanSamples:       make(map[string]*spanSample),
+		senders:           make(map[string]*senderInfo),
 	}
 }
 
@@ -39,47 +103,198 @@ func (s *TraceStorage) AddTraces(traces ptrace.Traces) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Drop expired batches first so they don't count against the limits
+	s.expireOldTracesLocked()
+
 	// Clone the traces to avoid any mutation issues
 	cloned := ptrace.NewTraces()
 	traces.CopyTo(cloned)
+	if s.config.Debug {
+		s.logBatchBreakdown(cloned)
+	}
+	if n := countMalformedTraceIDs(cloned); n > 0 {
+		log.Printf("Warning: Received %d span(s) with an all-zero trace ID; they're listed separately in the report", n)
+		s.malformedTraceIDSpans += n
+	}
+
+	if len(s.config.dropRules) > 0 {
+		if dropped := dropMatchingSpans(cloned, s.config.dropRules); dropped > 0 {
+			s.filteredSpans += dropped
+			if cloned.ResourceSpans().Len() == 0 {
+				return
+			}
+		}
+	}
+
+	if s.config.MaxSpansStoredPerTrace > 0 {
+		s.sampleSpansLocked(cloned)
+		if cloned.ResourceSpans().Len() == 0 {
+			return
+		}
+	}
 
 	// Calculate approximate size
 	spanCount := s.countSpans(cloned)
 	estimatedSize := s.estimateSize(cloned, spanCount)
 
 	entry := traceEntry{
-		traces:    cloned,
-		timestamp: time.Now(),
-		sizeBytes: estimatedSize,
+		traces:       cloned,
+		timestamp:    time.Now(),
+		sizeBytes:    estimatedSize,
+		serviceBytes: s.estimateServiceSizes(cloned),
+	}
+
+	// Check per-service budgets so one noisy service only evicts its own traces
+	if s.config.PerServiceMemoryMB > 0 {
+		budget := int64(s.config.PerServiceMemoryMB) * 1024 * 1024
+		for service, size := range entry.serviceBytes {
+			if s.serviceBytes[service]+size > budget {
+				log.Printf("Warning: Service %q reached its memory budget (%d MB), dropping its oldest traces", service, s.config.PerServiceMemoryMB)
+				s.evictServiceUntilRoom(service, size, budget)
+			}
+		}
 	}
 
 	// Check memory limit before adding
 	if s.config.MaxMemoryMB > 0 {
-		maxBytes := int64(s.config.MaxMemoryMB) * 1024 * 1024
-		if s.totalSizeBytes+estimatedSize > maxBytes {
-			log.Printf("Warning: Memory limit reached (%d MB), dropping oldest traces", s.config.MaxMemoryMB)
+		if s.totalSizeBytes+estimatedSize > s.config.storageLimitBytes() {
+			log.Printf("Warning: Memory limit reached (%d MB), dropping oldest traces", s.config.MaxMemoryMB-s.config.MemoryHeadroomMB)
 			s.evictOldestUntilRoom(estimatedSize)
 		}
 	}
 
-	// Check trace count limit
+	// Check trace count limit; it may have been lowered at runtime, so drop
+	// as many as needed
 	if s.config.MaxTraces > 0 && len(s.traces) >= s.config.MaxTraces {
 		log.Printf("Warning: Max trace count reached (%d), dropping oldest trace", s.config.MaxTraces)
-		s.removeOldest()
+		for len(s.traces) >= s.config.MaxTraces {
+			s.removeOldest()
+		}
 	}
 
 	s.traces = append(s.traces, entry)
 	s.totalSizeBytes += estimatedSize
 	s.totalSpanCount += spanCount
+	s.lifetimeBatches++
+	s.lifetimeSpans += spanCount
+	for service, size := range entry.serviceBytes {
+		s.serviceBytes[service] += size
+	}
+	if s.config.TraceTimeout > 0 {
+		s.trackArrivalsLocked(cloned, entry.timestamp)
+	}
 
 	log.Printf("Received trace batch: %d spans, ~%d KB (total: %d batches, %d spans, ~%.2f MB)",
 		spanCount, estimatedSize/1024, len(s.traces), s.totalSpanCount, float64(s.totalSizeBytes)/(1024*1024))
 }
 
+// OnTraceComplete registers a handler called when a trace receives no new
+// spans within TraceTimeout. Handlers run without the storage lock held.
+func (s *TraceStorage) OnTraceComplete(handler func(completedTrace)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completionHandlers = append(s.completionHandlers, handler)
+}
+
+// WatchTraceCompletion periodically marks idle traces as complete and
+// notifies the registered handlers until stop is closed
+func (s *TraceStorage) WatchTraceCompletion(stop <-chan struct{}) {
+	interval := s.config.TraceTimeout / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.completeIdleTraces(now)
+		}
+	}
+}
+
+// completeIdleTraces finds traces idle for longer than TraceTimeout and
+// notifies the completion handlers about them
+func (s *TraceStorage) completeIdleTraces(now time.Time) {
+	s.mu.Lock()
+	var completed []completedTrace
+	for traceID, pending := range s.pendingTraces {
+		if now.Sub(pending.lastArrival) >= s.config.TraceTimeout {
+			completed = append(completed, completedTrace{
+				traceID:   traceID,
+				spanCount: pending.spanCount,
+				duration:  pending.lastArrival.Sub(pending.firstArrival),
+			})
+			delete(s.pendingTraces, traceID)
+			s.recentlyCompleted[traceID] = now
+		}
+	}
+	for traceID, completedAt := range s.recentlyCompleted {
+		if now.Sub(completedAt) >= completedTraceMemory*s.config.TraceTimeout {
+			delete(s.recentlyCompleted, traceID)
+		}
+	}
+	s.completedTraces += len(completed)
+	handlers := s.completionHandlers
+	s.mu.Unlock()
+
+	for _, trace := range completed {
+		for _, handler := range handlers {
+			handler(trace)
+		}
+	}
+}
+
+// trackArrivalsLocked records the arrival time of every trace in a batch.
+// Spans without a trace ID and late spans of recently completed traces
+// aren't tracked.
+// Must be called with lock held
+func (s *TraceStorage) trackArrivalsLocked(traces ptrace.Traces, arrival time.Time) {
+	late := 0
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		rs := traces.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				if spans.At(k).TraceID().IsEmpty() {
+					continue
+				}
+				traceID := spans.At(k).TraceID().String()
+				if _, ok := s.recentlyCompleted[traceID]; ok {
+					late++
+					continue
+				}
+				pending, ok := s.pendingTraces[traceID]
+				if !ok {
+					pending = &pendingTrace{firstArrival: arrival}
+					s.pendingTraces[traceID] = pending
+				}
+				pending.lastArrival = arrival
+				pending.spanCount++
+			}
+		}
+	}
+	if late > 0 {
+		s.config.debugf("%d late span(s) arrived for traces already marked complete", late)
+	}
+}
+
+// ExpireOldTraces removes batches older than the trace expiration, e.g.
+// before a report is written
+func (s *TraceStorage) ExpireOldTraces() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireOldTracesLocked()
+}
+
 // GetTraces returns all stored traces, applying expiration
 func (s *TraceStorage) GetTraces() []ptrace.Traces {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Expiration mutates the stored slice, so this needs the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	s.expireOldTracesLocked()
 
@@ -90,6 +305,139 @@ func (s *TraceStorage) GetTraces() []ptrace.Traces {
 	return result
 }
 
+// storageSnapshot is a point-in-time copy of the stored batches and counters
+// that reports are rendered from
+type storageSnapshot struct {
+	traces          []traceEntry
+	droppedTraces   int
+	droppedOldest   int
+	sampledSpans    int
+	filteredSpans   int
+	lifetimeBatches int
+	lifetimeSpans   int
+
+	malformedTraceIDSpans int
+}
+
+// Snapshot captures the stored batches and counters under a short lock so a
+// report is rendered from one consistent view. Spans arriving afterwards are
+// kept in storage for the next report instead of racing with rendering.
+//
+// Only the slice header is copied: entries are clones that are never mutated
+// after insertion, appends write past the snapshot's length and eviction or
+// expiration only reslice or replace the storage slice, so the snapshot's view
+// of the backing array stays valid without holding the lock.
+func (s *TraceStorage) Snapshot() *storageSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &storageSnapshot{
+		traces:          s.traces[:len(s.traces):len(s.traces)],
+		droppedTraces:   s.droppedTraces,
+		droppedOldest:   s.droppedOldest,
+		sampledSpans:    s.sampledSpans,
+		filteredSpans:   s.filteredSpans,
+		lifetimeBatches: s.lifetimeBatches,
+		lifetimeSpans:   s.lifetimeSpans,
+
+		malformedTraceIDSpans: s.malformedTraceIDSpans,
+	}
+}
+
+// RecordSender notes an export request from a peer, logging the first
+// contact from each distinct protocol, host and user agent
+func (s *TraceStorage) RecordSender(protocol, remoteAddr, userAgent string) {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	if userAgent == "" {
+		userAgent = "unknown"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := protocol + "|" + host + "|" + userAgent
+	sender, ok := s.senders[key]
+	if !ok {
+		sender = &senderInfo{protocol: protocol, host: host, userAgent: userAgent, firstSeen: now}
+		s.senders[key] = sender
+		log.Printf("New sender: %s via %s (user-agent: %s)", host, protocol, userAgent)
+	}
+	sender.lastSeen = now
+	sender.requests++
+}
+
+// GetSenders returns the exporters seen so far, ordered by first contact
+func (s *TraceStorage) GetSenders() []senderInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	senders := make([]senderInfo, 0, len(s.senders))
+	for _, sender := range s.senders {
+		senders = append(senders, *sender)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		if !senders[i].firstSeen.Equal(senders[j].firstSeen) {
+			return senders[i].firstSeen.Before(senders[j].firstSeen)
+		}
+		if senders[i].host != senders[j].host {
+			return senders[i].host < senders[j].host
+		}
+		if senders[i].protocol != senders[j].protocol {
+			return senders[i].protocol < senders[j].protocol
+		}
+		return senders[i].userAgent < senders[j].userAgent
+	})
+	return senders
+}
+
+// Clear discards all stored traces and resets the storage counters,
+// returning how many batches were removed
+func (s *TraceStorage) Clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cleared := len(s.traces)
+	s.traces = make([]traceEntry, 0)
+	s.totalSizeBytes = 0
+	s.totalSpanCount = 0
+	s.droppedTraces = 0
+	s.droppedOldest = 0
+	s.serviceBytes = make(map[string]int64)
+	s.pendingTraces = make(map[string]*pendingTrace)
+	s.recentlyCompleted = make(map[string]time.Time)
+	s.completedTraces = 0
+	s.spanSamples = make(map[string]*spanSample)
+	s.sampledSpans = 0
+	s.filteredSpans = 0
+	s.malformedTraceIDSpans = 0
+	return cleared
+}
+
+// SampledSpans returns how many spans were dropped by MaxSpansStoredPerTrace
+func (s *TraceStorage) SampledSpans() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampledSpans
+}
+
+// FilteredSpans returns how many spans were dropped by DropSpanMatching rules
+func (s *TraceStorage) FilteredSpans() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filteredSpans
+}
+
+// CompletedTraces returns how many traces have been marked complete by TraceTimeout
+func (s *TraceStorage) CompletedTraces() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.completedTraces
+}
+
 // GetStats returns storage statistics
 func (s *TraceStorage) GetStats() (batches, spans, droppedTraces, droppedOldest int, memoryMB float64) {
 	s.mu.RLock()
@@ -97,6 +445,73 @@ func (s *TraceStorage) GetStats() (batches, spans, droppedTraces, droppedOldest
 	return len(s.traces), s.totalSpanCount, s.droppedTraces, s.droppedOldest, float64(s.totalSizeBytes) / (1024 * 1024)
 }
 
+// LifetimeStats returns how many batches and spans were stored over the whole
+// run, including those since evicted, expired or cleared
+func (s *TraceStorage) LifetimeStats() (batches, spans int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lifetimeBatches, s.lifetimeSpans
+}
+
+// storageLimits are the storage limits that can be changed at runtime with
+// POST /api/config
+type storageLimits struct {
+	TraceExpiration string `json:"trace_expiration"`
+	MaxTraces       int    `json:"max_traces"`
+	MaxMemoryMB     int    `json:"max_memory_mb"`
+}
+
+// Limits returns the current storage limits
+func (s *TraceStorage) Limits() storageLimits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limitsLocked()
+}
+
+// limitsLocked returns the current storage limits
+// Must be called with lock held
+func (s *TraceStorage) limitsLocked() storageLimits {
+	return storageLimits{
+		TraceExpiration: s.config.TraceExpiration.String(),
+		MaxTraces:       s.config.MaxTraces,
+		MaxMemoryMB:     s.config.MaxMemoryMB,
+	}
+}
+
+// SetLimits changes the storage limits at runtime; nil values are left
+// unchanged. Stored traces are trimmed to the new limits on the next
+// AddTraces, and expired ones also before a report is written.
+func (s *TraceStorage) SetLimits(expiration *time.Duration, maxTraces, maxMemoryMB *int) (storageLimits, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expiration != nil && *expiration < 0 {
+		return storageLimits{}, fmt.Errorf("trace expiration cannot be negative: %v", *expiration)
+	}
+	if maxTraces != nil && *maxTraces < 0 {
+		return storageLimits{}, fmt.Errorf("max traces cannot be negative: %d", *maxTraces)
+	}
+	if maxMemoryMB != nil {
+		if *maxMemoryMB < 0 {
+			return storageLimits{}, fmt.Errorf("max memory cannot be negative: %d", *maxMemoryMB)
+		}
+		if *maxMemoryMB > 0 && s.config.MemoryHeadroomMB >= *maxMemoryMB {
+			return storageLimits{}, fmt.Errorf("max memory (%d MB) must be more than the memory headroom (%d MB)", *maxMemoryMB, s.config.MemoryHeadroomMB)
+		}
+	}
+
+	if expiration != nil {
+		s.config.TraceExpiration = *expiration
+	}
+	if maxTraces != nil {
+		s.config.MaxTraces = *maxTraces
+	}
+	if maxMemoryMB != nil {
+		s.config.MaxMemoryMB = *maxMemoryMB
+	}
+	return s.limitsLocked(), nil
+}
+
 // expireOldTracesLocked removes traces older than the configured expiration time
 // Must be called with lock held
 func (s *TraceStorage) expireOldTracesLocked() {
@@ -111,13 +526,13 @@ func (s *TraceStorage) expireOldTracesLocked() {
 		if entry.timestamp.After(cutoff) {
 			newTraces = append(newTraces, entry)
 		} else {
-			spanCount := s.countSpans(entry.traces)
-			s.totalSizeBytes -= entry.sizeBytes
-			s.totalSpanCount -= spanCount
+			s.releaseEntry(entry)
 			s.droppedOldest++
 		}
 	}
 
+	s.config.debugf("Expiration scan: %d of %d batches older than %v (cutoff %s)",
+		len(s.traces)-len(newTraces), len(s.traces), s.config.TraceExpiration, cutoff.Format(time.RFC3339))
 	if len(newTraces) < len(s.traces) {
 		expired := len(s.traces) - len(newTraces)
 		log.Printf("Expired %d old trace batches (older than %v)", expired, s.config.TraceExpiration)
@@ -125,29 +540,149 @@ func (s *TraceStorage) expireOldTracesLocked() {
 	}
 }
 
-// evictOldestUntilRoom removes oldest traces until there's room for newSize
+// countMalformedTraceIDs counts spans with an all-zero trace ID
+func countMalformedTraceIDs(traces ptrace.Traces) int {
+	count := 0
+	forEachSpan(traces, func(span ptrace.Span) {
+		if span.TraceID().IsEmpty() {
+			count++
+		}
+	})
+	return count
+}
+
+// logBatchBreakdown logs the shape of an incoming batch at debug level:
+// resource and scope counts and how many spans each trace contributed
+func (s *TraceStorage) logBatchBreakdown(traces ptrace.Traces) {
+	scopes := 0
+	perTrace := make(map[string]int)
+	var order []string
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		scopes += traces.ResourceSpans().At(i).ScopeSpans().Len()
+	}
+	forEachSpan(traces, func(span ptrace.Span) {
+		id := span.TraceID().String()
+		if perTrace[id] == 0 {
+			order = append(order, id)
+		}
+		perTrace[id]++
+	})
+
+	s.config.debugf("Batch: %d resources, %d scopes, %d traces", traces.ResourceSpans().Len(), scopes, len(order))
+	for _, id := range order {
+		s.config.debugf("  trace %s: %d spans", id, perTrace[id])
+	}
+}
+
+// evictOldestUntilRoom removes oldest traces until there's room for newSize.
+// With per-service budgets enabled, the oldest trace of the service using the
+// most memory is evicted first so quiet services keep their traces.
 // Must be called with lock held
 func (s *TraceStorage) evictOldestUntilRoom(newSize int64) {
-	maxBytes := int64(s.config.MaxMemoryMB) * 1024 * 1024
+	maxBytes := s.config.storageLimitBytes()
 
 	for len(s.traces) > 0 && s.totalSizeBytes+newSize > maxBytes {
+		if s.config.PerServiceMemoryMB > 0 {
+			if i := s.oldestIndexForService(s.largestService()); i >= 0 {
+				s.removeAt(i)
+				continue
+			}
+		}
 		s.removeOldest()
 	}
 }
 
+// evictServiceUntilRoom removes the oldest traces containing service until it
+// has room for newSize within budget
+// Must be called with lock held
+func (s *TraceStorage) evictServiceUntilRoom(service string, newSize, budget int64) {
+	for s.serviceBytes[service]+newSize > budget {
+		i := s.oldestIndexForService(service)
+		if i < 0 {
+			return
+		}
+		s.removeAt(i)
+	}
+}
+
+// largestService returns the service currently using the most memory
+// Must be called with lock held
+func (s *TraceStorage) largestService() string {
+	largest := ""
+	for service, size := range s.serviceBytes {
+		if largest == "" || size > s.serviceBytes[largest] ||
+			(size == s.serviceBytes[largest] && service < largest) {
+			largest = service
+		}
+	}
+	return largest
+}
+
+// oldestIndexForService returns the index of the oldest batch containing
+// spans from service, or -1 if there is none
+// Must be called with lock held
+func (s *TraceStorage) oldestIndexForService(service string) int {
+	for i, entry := range s.traces {
+		if _, ok := entry.serviceBytes[service]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
 // removeOldest removes the oldest trace
 // Must be called with lock held
 func (s *TraceStorage) removeOldest() {
-	if len(s.traces) == 0 {
+	s.removeAt(0)
+}
+
+// removeAt removes the trace batch at index i
+// Must be called with lock held
+func (s *TraceStorage) removeAt(i int) {
+	if i < 0 || i >= len(s.traces) {
 		return
 	}
 
-	oldest := s.traces[0]
-	spanCount := s.countSpans(oldest.traces)
-	s.totalSizeBytes -= oldest.sizeBytes
-	s.totalSpanCount -= spanCount
+	entry := s.traces[i]
+	s.config.debugf("Evicting batch %d of %d received %v ago (~%d KB, %d spans; storage ~%.2f MB)",
+		i+1, len(s.traces), time.Since(entry.timestamp).Round(time.Millisecond), entry.sizeBytes/1024,
+		s.countSpans(entry.traces), float64(s.totalSizeBytes)/(1024*1024))
+	s.releaseEntry(entry)
 	s.droppedOldest++
-	s.traces = s.traces[1:]
+
+	if i == 0 {
+		s.traces = s.traces[1:]
+		return
+	}
+
+	// Build a new slice rather than shifting in place: snapshots share the
+	// current backing array and must not see it change underneath them
+	remaining := make([]traceEntry, 0, len(s.traces)-1)
+	remaining = append(remaining, s.traces[:i]...)
+	remaining = append(remaining, s.traces[i+1:]...)
+	s.traces = remaining
+}
+
+// releaseEntry subtracts a removed batch from the storage totals
+// Must be called with lock held
+func (s *TraceStorage) releaseEntry(entry traceEntry) {
+	s.subtractEntryTotals(entry)
+	if s.config.MaxSpansStoredPerTrace > 0 {
+		s.forgetStoredSpans(entry.traces)
+	}
+}
+
+// subtractEntryTotals subtracts a batch's size and spans from the storage totals
+// Must be called with lock held
+func (s *TraceStorage) subtractEntryTotals(entry traceEntry) {
+	s.totalSizeBytes -= entry.sizeBytes
+	s.totalSpanCount -= s.countSpans(entry.traces)
+	for service, size := range entry.serviceBytes {
+		s.serviceBytes[service] -= size
+		if s.serviceBytes[service] <= 0 {
+			delete(s.serviceBytes, service)
+		}
+	}
 }
 
 // countSpans counts total spans in a trace batch
@@ -176,3 +711,24 @@ func (s *TraceStorage) estimateSize(traces ptrace.Traces, spanCount int) int64 {
 
 	return baseSize + resourceSize + spanSize
 }
+
+// estimateServiceSizes splits a batch's estimated size by service.name using
+// the same per-resource and per-span estimates as estimateSize
+func (s *TraceStorage) estimateServiceSizes(traces ptrace.Traces) map[string]int64 {
+	sizes := make(map[string]int64)
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		rs := traces.ResourceSpans().At(i)
+		service, _ := resourceServiceName(rs.Resource(), s.config.serviceNameKeys)
+		// Without a resource name, go by the span attribute of the first span
+		if service == "unknown" && rs.ScopeSpans().Len() > 0 && rs.ScopeSpans().At(0).Spans().Len() > 0 {
+			service, _ = spanServiceName(rs.Resource(), rs.ScopeSpans().At(0).Spans().At(0), s.config)
+		}
+
+		size := int64(500)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			size += int64(rs.ScopeSpans().At(j).Spans().Len() * 1024)
+		}
+		sizes[service] += size
+	}
+	return sizes
+}
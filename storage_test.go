@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestSampleSpansAcrossBatches(t *testing.T) {
+	config := *testConfig(t)
+	config.MaxSpansStoredPerTrace = 100
+	storage := NewTraceStorage(&config)
+
+	// One root followed by 10 batches of 100 children, like a long-running
+	// trace flushed by its exporter over time
+	traceID := pcommon.TraceID([16]byte{1})
+	root := ptrace.NewTraces()
+	rootSpan := root.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	rootSpan.SetTraceID(traceID)
+	rootSpan.SetSpanID(pcommon.SpanID([8]byte{0xff}))
+	storage.AddTraces(root)
+	for b := 0; b < 10; b++ {
+		batch := ptrace.NewTraces()
+		spans := batch.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		for i := 0; i < 100; i++ {
+			span := spans.AppendEmpty()
+			span.SetTraceID(traceID)
+			span.SetSpanID(pcommon.SpanID([8]byte{byte(b), byte(i)}))
+			span.SetParentSpanID(rootSpan.SpanID())
+			if b == 9 && i == 99 {
+				span.Status().SetCode(ptrace.StatusCodeError)
+			}
+		}
+		storage.AddTraces(batch)
+	}
+
+	perBatch := make([]int, 10)
+	stored, roots, errored := 0, 0, 0
+	for _, entry := range storage.Snapshot().traces {
+		forEachSpan(entry.traces, func(span ptrace.Span) {
+			stored++
+			switch {
+			case span.ParentSpanID().IsEmpty():
+				roots++
+			case span.Status().Code() == ptrace.StatusCodeError:
+				errored++
+			default:
+				perBatch[span.SpanID()[0]]++
+			}
+		})
+	}
+
+	if roots != 1 || errored != 1 {
+		t.Errorf("root and error spans must survive sampling, got %d roots and %d errors", roots, errored)
+	}
+	if sampled := stored - roots - errored; sampled > 100 || sampled < 25 {
+		t.Errorf("stored %d sampled spans, want at most 100 and a meaningful share", sampled)
+	}
+	if perBatch[0] == 0 || perBatch[9] == 0 {
+		t.Errorf("sample isn't spread across the trace: spans kept per batch %v", perBatch)
+	}
+	if dropped := storage.SampledSpans(); stored+dropped != 1001 {
+		t.Errorf("stored %d + dropped %d spans, want 1001 received", stored, dropped)
+	}
+	if _, spans, _, _, _ := storage.GetStats(); spans != stored {
+		t.Errorf("storage totals count %d spans, %d are stored", spans, stored)
+	}
+}
+
+func TestSetLimitsExpiration(t *testing.T) {
+	config := *testConfig(t)
+	config.TraceExpiration = 0
+	storage := NewTraceStorage(&config)
+
+	newBatch := func(id byte) ptrace.Traces {
+		traces := ptrace.NewTraces()
+		span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID([16]byte{id}))
+		span.SetSpanID(pcommon.SpanID([8]byte{id}))
+		return traces
+	}
+	storage.AddTraces(newBatch(1))
+	storage.AddTraces(newBatch(2))
+	// Age the first batch past the expiration set below
+	storage.traces[0].timestamp = time.Now().Add(-time.Hour)
+
+	expiration := 30 * time.Minute
+	if _, err := storage.SetLimits(&expiration, nil, nil); err != nil {
+		t.Fatalf("SetLimits: %v", err)
+	}
+
+	t.Run("before a report", func(t *testing.T) {
+		storage.ExpireOldTraces()
+		if batches, _, _, _, _ := storage.GetStats(); batches != 1 {
+			t.Errorf("%d batches stored after expiring, want 1", batches)
+		}
+	})
+
+	t.Run("on the next batch", func(t *testing.T) {
+		storage.traces[0].timestamp = time.Now().Add(-time.Hour)
+		storage.AddTraces(newBatch(3))
+		if batches, spans, _, _, _ := storage.GetStats(); batches != 1 || spans != 1 {
+			t.Errorf("%d batches with %d spans stored, want only the new one", batches, spans)
+		}
+	})
+}
+
+func TestTraceCompletionLateSpans(t *testing.T) {
+	config := *testConfig(t)
+	config.TraceTimeout = time.Second
+	storage := NewTraceStorage(&config)
+
+	var completed []completedTrace
+	storage.OnTraceComplete(func(trace completedTrace) { completed = append(completed, trace) })
+	newBatch := func(traceID pcommon.TraceID) ptrace.Traces {
+		traces := ptrace.NewTraces()
+		span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(traceID)
+		return traces
+	}
+
+	storage.AddTraces(newBatch(pcommon.TraceID([16]byte{1})))
+	storage.AddTraces(newBatch(pcommon.TraceID{}))
+	storage.completeIdleTraces(time.Now().Add(2 * time.Second))
+	if len(completed) != 1 {
+		t.Fatalf("completed %d traces, want 1 (spans without a trace ID aren't a trace)", len(completed))
+	}
+
+	// A late span must not re-open the trace and complete it a second time
+	storage.AddTraces(newBatch(pcommon.TraceID([16]byte{1})))
+	storage.completeIdleTraces(time.Now().Add(4 * time.Second))
+	if len(completed) != 1 || storage.CompletedTraces() != 1 {
+		t.Errorf("trace completed %d times, want once", len(completed))
+	}
+}
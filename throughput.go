@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxRateBuckets caps how many bars the arrival rate sparkline has; longer
+// sessions use wider buckets
+const maxRateBuckets = 60
+
+// arrivalRate buckets trace arrivals over the collection window
+type arrivalRate struct {
+	start  time.Time
+	bucket time.Duration
+	counts []int
+}
+
+// computeArrivalRate counts traces by when their first span arrived, in
+// one-minute buckets, widened to whole minutes so there are at most
+// maxRateBuckets of them
+func computeArrivalRate(traces []*traceInfo) (arrivalRate, bool) {
+	var first, last time.Time
+	for _, ti := range traces {
+		if ti.firstArrival.IsZero() {
+			continue
+		}
+		if first.IsZero() || ti.firstArrival.Before(first) {
+			first = ti.firstArrival
+		}
+		if ti.firstArrival.After(last) {
+			last = ti.firstArrival
+		}
+	}
+	if first.IsZero() {
+		return arrivalRate{}, false
+	}
+
+	start := first.Truncate(time.Minute)
+	window := last.Sub(start)
+	bucket := time.Minute
+	if minutes := int(window/time.Minute) + 1; minutes > maxRateBuckets {
+		bucket = time.Duration((minutes+maxRateBuckets-1)/maxRateBuckets) * time.Minute
+	}
+
+	rate := arrivalRate{start: start, bucket: bucket, counts: make([]int, int(window/bucket)+1)}
+	for _, ti := range traces {
+		if !ti.firstArrival.IsZero() {
+			rate.counts[int(ti.firstArrival.Sub(start)/bucket)]++
+		}
+	}
+	return rate, true
+}
+
+// perMinute returns a bucket's count as traces per minute
+func (r arrivalRate) perMinute(count int) float64 {
+	return float64(count) / r.bucket.Minutes()
+}
+
+// String renders the rate for the overview, e.g.
+// "`▁▃█▂` peak 42.0/min at 10:31, avg 12.5/min over 4 min"
+func (r arrivalRate) String() string {
+	peak, total := 0, 0
+	for i, c := range r.counts {
+		total += c
+		if c > r.counts[peak] {
+			peak = i
+		}
+	}
+	minutes := time.Duration(len(r.counts)) * r.bucket
+	return fmt.Sprintf("`%s` peak %.1f/min at %s, avg %.1f/min over %v",
+		sparkBars(r.counts), r.perMinute(r.counts[peak]), r.start.Add(time.Duration(peak)*r.bucket).Format("15:04"),
+		float64(total)/minutes.Minutes(), minutes)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// spanTimestampLayout formats -span-timestamps wall-clock times, to the
+// microsecond so they line up with application log lines
+const spanTimestampLayout = "2006-01-02 15:04:05.000000 MST"
+
+// wallClock converts t to the -timezone location
+func (c *Config) wallClock(t time.Time) time.Time {
+	if c.location == nil {
+		return t
+	}
+	return t.In(c.location)
+}
+
+// formatSpanTimestamp renders a span timestamp as wall-clock time in -timezone
+func formatSpanTimestamp(ts pcommon.Timestamp, config *Config) string {
+	return config.wallClock(ts.AsTime()).Format(spanTimestampLayout)
+}
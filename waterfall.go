@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// writeSpanWaterfall writes the -view waterfall timeline: every span in start
+// order, indented by depth, with its bar offset by when it started relative
+// to the trace. Unlike the tree, overlap between sibling subtrees is visible
+// at a glance.
+func writeSpanWaterfall(f io.Writer, tree *spanTreeNode, traceDuration time.Duration, layout timelineLayout) {
+	var nodes []*spanTreeNode
+	stack := []*spanTreeNode{tree}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		nodes = append(nodes, node)
+		stack = append(stack, node.children...)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		si, sj := nodes[i].spanInfo.span.StartTimestamp(), nodes[j].spanInfo.span.StartTimestamp()
+		if si != sj {
+			return si < sj
+		}
+		return nodes[i].spanIndex < nodes[j].spanIndex
+	})
+
+	for _, node := range nodes {
+		span := node.spanInfo.span
+		duration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
+
+		offset, barLength := 0, layout.barWidth
+		if traceDuration > 0 {
+			start := time.Duration(0)
+			if span.StartTimestamp() > layout.traceStart {
+				start = time.Duration(span.StartTimestamp() - layout.traceStart)
+			}
+			offset = min(int(float64(start)/float64(traceDuration)*float64(layout.barWidth)), layout.barWidth-1)
+			barLength = int(float64(duration) / float64(traceDuration) * float64(layout.barWidth))
+		}
+		bar := spanBar(span, min(barLength, layout.barWidth-offset), layout)
+
+		// Indentation comes out of the name column so bars stay aligned
+		indent := strings.Repeat("  ", min(node.depth, layout.nameWidth/4))
+		label := indent + spanLabel(node, timelineLayout{nameWidth: layout.nameWidth - len(indent)})
+		fmt.Fprintf(f, "%-*s [%6s] │%s%s%s│%s\n", layout.nameWidth, label, formatDuration(duration, layout.precision),
+			strings.Repeat(" ", offset), bar, strings.Repeat(" ", max(layout.barWidth-offset-utf8.RuneCountInString(bar), 0)),
+			spanStatusIndicator(node, layout))
+	}
+}